@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Episode calendar
+//
+// /api/calendar lists episodes airing in a date window, optionally for a set
+// of shows. /shows/:id/calendar.ics and /calendar.ics?show_ids= serve the
+// same data as iCalendar feeds for calendar apps to subscribe to. Air dates
+// come from TMDB via maybeFetchEpisodes, which refetches recently aired and
+// upcoming episodes on every cooldown expiry so the feeds follow schedule
+// changes.
+
+const (
+	calendarDateLayout = "2006-01-02"
+	calendarMaxDays    = 366 // widest /api/calendar window
+	calendarMaxShows   = 500 // most show_ids per request
+	calendarLimit      = 2000
+	icsPastDays        = 90  // feeds start this many days back...
+	icsFutureDays      = 365 // ...and end this many days ahead
+)
+
+// CalendarEpisode is an episode on /api/calendar. TBA episodes have no air
+// date yet but follow the show's last dated episode, i.e. they are announced
+// but not scheduled.
+type CalendarEpisode struct {
+	EpisodeID      int         `json:"episode_id"`
+	SeasonID       int         `json:"season_id"`
+	SeasonNumber   int         `json:"season_number"`
+	EpisodeNumber  int         `json:"episode_number"`
+	DisplayName    *string     `json:"display_name,omitempty"`
+	Synopsis       *string     `json:"synopsis,omitempty"`
+	AirDate        *string     `json:"air_date,omitempty"`
+	RuntimeMinutes *int        `json:"runtime_minutes,omitempty"`
+	ImageURL       *string     `json:"image_url,omitempty"`
+	ImageBlurhash  *string     `json:"image_blurhash,omitempty"`
+	ImageColor     *string     `json:"image_color,omitempty"`
+	TBA            bool        `json:"tba"`
+	Show           EpisodeShow `json:"show"`
+}
+
+const calendarColumns = `
+	e.id, e.season_id, ss.season, e.episode, e.display_name, e.synopsis,
+	TO_CHAR(e.air_date, 'YYYY-MM-DD'), e.runtime_minutes, e.image_url, e.image_blurhash, e.image_color,
+	s.id, t.id, t.display_name, t.start_year, t.image_url, t.image_blurhash, t.image_color, t.num_votes`
+
+const calendarFrom = `
+	FROM show_episodes e
+	JOIN show_seasons ss ON ss.id = e.season_id
+	JOIN shows s ON s.id = ss.show_id
+	JOIN titles t ON t.id = s.title_id`
+
+func scanCalendarEpisodes(query string, args ...any) ([]CalendarEpisode, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []CalendarEpisode
+	for rows.Next() {
+		var e CalendarEpisode
+		err := rows.Scan(&e.EpisodeID, &e.SeasonID, &e.SeasonNumber, &e.EpisodeNumber, &e.DisplayName, &e.Synopsis,
+			&e.AirDate, &e.RuntimeMinutes, &e.ImageURL, &e.ImageBlurhash, &e.ImageColor,
+			&e.Show.ShowID, &e.Show.TitleID, &e.Show.DisplayName, &e.Show.StartYear, &e.Show.ImageURL, &e.Show.ImageBlurhash, &e.Show.ImageColor, &e.Show.NumVotes)
+		if err != nil {
+			return nil, err
+		}
+		if !hasImage(e.ImageURL) {
+			e.ImageURL = nil
+		}
+		if !hasImage(e.Show.ImageURL) {
+			e.Show.ImageURL = nil
+		}
+		e.ImageURL = proxyImage("still", e.EpisodeID, e.ImageURL)
+		e.Show.ImageURL = proxyImage("poster", e.Show.TitleID, e.Show.ImageURL)
+		e.TBA = e.AirDate == nil
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
+
+// limitCalendar cuts episodes, fetched with a LIMIT of calendarLimit+1, to
+// calendarLimit and reports whether any were cut.
+func limitCalendar(episodes []CalendarEpisode, err error) ([]CalendarEpisode, bool, error) {
+	if len(episodes) > calendarLimit {
+		return episodes[:calendarLimit], true, err
+	}
+	return episodes, false, err
+}
+
+// calendarEpisodes returns episodes airing from..to (inclusive), soonest
+// first and more popular shows first within a day. showIDs restricts them
+// to those shows; nil means all shows. truncated is set when there were more
+// than calendarLimit.
+func calendarEpisodes(from, to string, showIDs []int) (episodes []CalendarEpisode, truncated bool, err error) {
+	w := newSQLWhere("")
+	w.and(`e.air_date BETWEEN ` + w.arg(from) + `::date AND ` + w.arg(to) + `::date`)
+	if showIDs != nil {
+		w.and(`ss.show_id = ANY(` + w.arg(pq.Array(showIDs)) + `)`)
+	}
+	return limitCalendar(scanCalendarEpisodes(`SELECT `+calendarColumns+calendarFrom+w.String()+`
+		ORDER BY e.air_date, t.num_votes DESC NULLS LAST, s.id, ss.season, e.episode
+		LIMIT `+strconv.Itoa(calendarLimit+1), w.args...))
+}
+
+// tbaEpisodes returns the given shows' episodes that have no air date and
+// come after each show's last dated episode. Shows with no dated episodes
+// (never fetched from TMDB) have none.
+func tbaEpisodes(showIDs []int) (episodes []CalendarEpisode, truncated bool, err error) {
+	return limitCalendar(scanCalendarEpisodes(`SELECT `+calendarColumns+calendarFrom+`
+		WHERE ss.show_id = ANY($1) AND e.air_date IS NULL AND ss.season > 0
+		  AND (ss.season, e.episode) > (
+			SELECT ss2.season, e2.episode
+			FROM show_episodes e2 JOIN show_seasons ss2 ON ss2.id = e2.season_id
+			WHERE ss2.show_id = ss.show_id AND e2.air_date IS NOT NULL
+			ORDER BY ss2.season DESC, e2.episode DESC LIMIT 1)
+		ORDER BY t.num_votes DESC NULLS LAST, s.id, ss.season, e.episode
+		LIMIT `+strconv.Itoa(calendarLimit+1), pq.Array(showIDs)))
+}
+
+// parseShowIDs parses a comma-separated show_ids param. An empty param
+// returns nil.
+func parseShowIDs(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > calendarMaxShows {
+		return nil, fmt.Errorf("at most %d show_ids", calendarMaxShows)
+	}
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid show id %q", p)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// refreshCalendarShows queues the TMDB episode fetch for shows whose
+// cooldown has expired, so feeds pick up new and rescheduled episodes.
+func refreshCalendarShows(showIDs []int) {
+	if !tmdbAPI.Enabled() || len(showIDs) == 0 {
+		return
+	}
+	rows, err := db.Query(`
+		SELECT t.id FROM shows s JOIN titles t ON t.id = s.title_id
+		WHERE s.id = ANY($1) AND (t.episodes_checked_at IS NULL OR t.episodes_checked_at < NOW() - INTERVAL '24 hours')
+	`, pq.Array(showIDs))
+	if err != nil {
+		return
+	}
+	var stale []int
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		stale = append(stale, id)
+	}
+	rows.Close()
+	queueEnrichment(jobEpisodes, priorityList, stale...)
+}
+
+func handleAPICalendar(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	q := r.URL.Query()
+	from, err := time.Parse(calendarDateLayout, q.Get("from"))
+	if q.Get("from") == "" {
+		from, err = time.Now().UTC().Truncate(24*time.Hour), nil
+	}
+	if err != nil {
+		jsonError(w, "from must be YYYY-MM-DD", 400)
+		return
+	}
+	to, err := time.Parse(calendarDateLayout, q.Get("to"))
+	if q.Get("to") == "" {
+		to, err = from.AddDate(0, 0, 7), nil
+	}
+	if err != nil {
+		jsonError(w, "to must be YYYY-MM-DD", 400)
+		return
+	}
+	if to.Before(from) || to.Sub(from) > calendarMaxDays*24*time.Hour {
+		jsonError(w, fmt.Sprintf("to must be from 0 to %d days after from", calendarMaxDays), 400)
+		return
+	}
+	showIDs, err := parseShowIDs(q.Get("show_ids"))
+	if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	refreshCalendarShows(showIDs)
+	episodes, truncated, err := calendarEpisodes(from.Format(calendarDateLayout), to.Format(calendarDateLayout), showIDs)
+	if err != nil {
+		log.Printf("calendarEpisodes error: %v", err)
+		jsonError(w, "Database error", 500)
+		return
+	}
+	// TBA episodes have no date to filter by, so they are only listed for
+	// explicit shows
+	tba := []CalendarEpisode{}
+	if showIDs != nil {
+		var tbaTruncated bool
+		tba, tbaTruncated, err = tbaEpisodes(showIDs)
+		truncated = truncated || tbaTruncated
+		if err != nil {
+			log.Printf("tbaEpisodes error: %v", err)
+			jsonError(w, "Database error", 500)
+			return
+		}
+	}
+	if episodes == nil {
+		episodes = []CalendarEpisode{}
+	}
+	if tba == nil {
+		tba = []CalendarEpisode{}
+	}
+	jsonResponse(w, map[string]any{
+		"from":      from.Format(calendarDateLayout),
+		"to":        to.Format(calendarDateLayout),
+		"episodes":  episodes,
+		"tba":       tba,
+		"truncated": truncated,
+	})
+}
+
+// handleShowCalendar serves /shows/:id/calendar.ics.
+func handleShowCalendar(w http.ResponseWriter, r *http.Request, showID int) {
+	var name string
+	err := db.QueryRow(`SELECT t.display_name FROM shows s JOIN titles t ON t.id = s.title_id WHERE s.id = $1`, showID).Scan(&name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	serveICS(w, r, name, []int{showID})
+}
+
+// handleListCalendar serves /calendar.ics?show_ids=, one feed for a list
+// of shows.
+func handleListCalendar(w http.ResponseWriter, r *http.Request) {
+	showIDs, err := parseShowIDs(r.URL.Query().Get("show_ids"))
+	if err != nil || showIDs == nil {
+		http.Error(w, "show_ids is required: a comma-separated list of show ids", 400)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = fmt.Sprintf("MediaCanon: %d shows", len(showIDs))
+	}
+	serveICS(w, r, name, showIDs)
+}
+
+// serveICS writes an iCalendar feed of the shows' dated episodes from
+// icsPastDays ago to icsFutureDays ahead, one all-day event per episode.
+// TBA episodes have no day to go on and are left out.
+func serveICS(w http.ResponseWriter, r *http.Request, name string, showIDs []int) {
+	refreshCalendarShows(showIDs)
+	now := time.Now().UTC()
+	episodes, truncated, err := calendarEpisodes(now.AddDate(0, 0, -icsPastDays).Format(calendarDateLayout),
+		now.AddDate(0, 0, icsFutureDays).Format(calendarDateLayout), showIDs)
+	if err != nil {
+		log.Printf("calendarEpisodes error: %v", err)
+		http.Error(w, "Database error", 500)
+		return
+	}
+	if truncated {
+		log.Printf("calendar feed for %d shows cut at %d episodes", len(showIDs), calendarLimit)
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := scheme + "://" + r.Host
+	stamp := now.Format("20060102T150405Z")
+
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//MediaCanon//Episode Calendar//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	icsLine(&b, "X-WR-CALNAME:"+icsEscape(name))
+	for _, e := range episodes {
+		day, err := time.Parse(calendarDateLayout, *e.AirDate)
+		if err != nil {
+			continue
+		}
+		summary := fmt.Sprintf("%s S%02dE%02d", e.Show.DisplayName, e.SeasonNumber, e.EpisodeNumber)
+		if e.DisplayName != nil && *e.DisplayName != "" {
+			summary += ": " + *e.DisplayName
+		}
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, fmt.Sprintf("UID:episode-%d@mediacanon.org", e.EpisodeID))
+		icsLine(&b, "DTSTAMP:"+stamp)
+		icsLine(&b, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+		icsLine(&b, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		icsLine(&b, "SUMMARY:"+icsEscape(summary))
+		if e.Synopsis != nil && *e.Synopsis != "" {
+			icsLine(&b, "DESCRIPTION:"+icsEscape(*e.Synopsis))
+		}
+		icsLine(&b, fmt.Sprintf("URL:%s/shows/%d", base, e.Show.ShowID))
+		icsLine(&b, "END:VEVENT")
+	}
+	icsLine(&b, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsEscape escapes an iCalendar TEXT value (RFC 5545 §3.3.11).
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsLine writes one content line, folded at 75 octets without splitting a
+// UTF-8 sequence (RFC 5545 §3.1).
+func icsLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
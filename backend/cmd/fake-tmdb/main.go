@@ -0,0 +1,78 @@
+// fake-tmdb serves the offline TMDB stand-in from internal/tmdb/tmdbtest, so
+// the server and the sync commands can run without a TMDB key or network:
+//
+//	go run ./cmd/fake-tmdb -addr :8090 &
+//	TMDB_API_KEY=fake TMDB_BASE_URL=http://localhost:8090 ./mediacanon
+//
+// It also serves generated images under /t/p/, for the image proxy:
+//
+//	IMAGE_ORIGIN=http://localhost:8090/t/p/original IMAGE_PROXY_URL=/img ./mediacanon
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mediacanon.org/backend/internal/tmdb/tmdbtest"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "Listen address")
+	fixtures := flag.String("fixtures", "", "Extra fixture directory, laid out like the API (tv/1396/season/1.json)")
+	latency := flag.Duration("latency", 0, "Delay every response by this much")
+	rateLimitEvery := flag.Int("429-every", 0, "Answer every Nth request with 429 (0 = never)")
+	retryAfter := flag.Duration("retry-after", time.Second, "Retry-After sent with 429s")
+	flat := flag.String("flat", "", "TMDB show ids to serve as one flat season 1 (comma-separated)")
+	notFound := flag.String("404", "", "API paths to answer with 404 (comma-separated, e.g. /tv/1396/season/2)")
+	record := flag.Bool("record", false, "Fetch fixtures missing from -fixtures from the real API and save them there (needs TMDB_API_KEY)")
+	flag.Parse()
+
+	fake := tmdbtest.New()
+	if *fixtures != "" {
+		if err := fake.LoadDir(*fixtures); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Loading fixtures: %v", err)
+		}
+	}
+	if *record {
+		if *fixtures == "" || os.Getenv("TMDB_API_KEY") == "" {
+			log.Fatal("-record needs -fixtures and TMDB_API_KEY")
+		}
+		fake.RecordFrom(os.Getenv("TMDB_UPSTREAM_URL"), os.Getenv("TMDB_API_KEY"), *fixtures)
+		log.Printf("Recording fixture misses into %s", *fixtures)
+	}
+	fake.SetLatency(*latency)
+	fake.RateLimitEvery(*rateLimitEvery, *retryAfter)
+	for _, s := range splitList(*flat) {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Bad -flat id %q", s)
+		}
+		fake.FlatSeasons(id)
+	}
+	for _, p := range splitList(*notFound) {
+		fake.NotFound(p)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		fake.ServeHTTP(w, r)
+		log.Printf("%s %s (%v)", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+	})
+	log.Printf("Fake TMDB listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -1,56 +1,39 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"os"
 	"time"
 
 	_ "github.com/lib/pq"
-)
-
-type TMDBFindResponse struct {
-	TVResults []struct {
-		ID               int      `json:"id"`
-		PosterPath       string   `json:"poster_path"`
-		OriginalLanguage string   `json:"original_language"`
-		FirstAirDate     string   `json:"first_air_date"`
-		Popularity       float64  `json:"popularity"`
-		OriginCountry    []string `json:"origin_country"`
-	} `json:"tv_results"`
-	MovieResults []struct {
-		ID               int      `json:"id"`
-		PosterPath       string   `json:"poster_path"`
-		OriginalLanguage string   `json:"original_language"`
-		ReleaseDate      string   `json:"release_date"`
-		Popularity       float64  `json:"popularity"`
-		OriginCountry    []string `json:"origin_country"`
-	} `json:"movie_results"`
-}
 
-type TMDBEpisodeResponse struct {
-	StillPath string `json:"still_path"`
-	AirDate   string `json:"air_date"`
-	Runtime   int    `json:"runtime"`
-}
+	"mediacanon.org/backend/internal/enrichstatus"
+	"mediacanon.org/backend/internal/tmdb"
+)
 
-var apiKey string
-var requestCount int
+var tmdbAPI *tmdb.Client
 var startTime time.Time
 
 func main() {
-	flag.StringVar(&apiKey, "key", "", "TMDB API key (required)")
+	apiKey := flag.String("key", "", "TMDB API key (required)")
+	baseURL := flag.String("tmdb-url", os.Getenv("TMDB_BASE_URL"), "TMDB API base URL (default "+tmdb.DefaultBaseURL+")")
+	envRPS, envBurst := tmdb.RateFromEnv(10)
+	rps := flag.Float64("rps", envRPS, "Maximum TMDB requests per second; default TMDB_RPS, else 10")
+	burst := flag.Int("burst", envBurst, "TMDB request burst; default TMDB_BURST, else 10")
 	dsn := flag.String("db", "postgres://localhost/mediacanon?sslmode=disable", "Database URL")
 	limit := flag.Int("limit", 0, "Limit number of shows to process (0 = all)")
-	skipSynced := flag.Bool("skip-synced", true, "Skip shows that already have image_url")
+	skipSynced := flag.Bool("skip-synced", true, "Skip shows that already have image_url, or whose last TMDB miss is not due for a retry")
 	flag.Parse()
 
-	if apiKey == "" {
+	if *apiKey == "" {
 		log.Fatal("TMDB API key required: -key YOUR_KEY")
 	}
+	tmdbAPI = tmdb.New(tmdb.Config{APIKey: *apiKey, BaseURL: *baseURL, RequestsPerSecond: *rps, Burst: *burst})
 
 	db, err := sql.Open("postgres", *dsn)
 	if err != nil {
@@ -62,13 +45,13 @@ func main() {
 
 	// Get all shows with IMDb IDs
 	query := `
-		SELECT s.id, t.imdb_id, t.display_name
+		SELECT s.id, t.id, t.imdb_id, t.display_name
 		FROM shows s
 		JOIN titles t ON s.title_id = t.id
 		WHERE t.imdb_id IS NOT NULL AND t.imdb_id != ''
 	`
 	if *skipSynced {
-		query += ` AND t.image_url IS NULL`
+		query += ` AND t.image_url IS NULL AND ` + enrichstatus.NotBlockedSQL(enrichstatus.EntityTitle, "t.id")
 	}
 	query += ` ORDER BY s.id`
 	if *limit > 0 {
@@ -82,13 +65,14 @@ func main() {
 
 	type show struct {
 		id      int
+		titleID int
 		imdbID  string
 		name    string
 	}
 	var shows []show
 	for rows.Next() {
 		var s show
-		rows.Scan(&s.id, &s.imdbID, &s.name)
+		rows.Scan(&s.id, &s.titleID, &s.imdbID, &s.name)
 		shows = append(shows, s)
 	}
 	rows.Close()
@@ -103,12 +87,12 @@ func main() {
 		// Progress every 100 shows
 		if (i+1)%100 == 0 || i == 0 {
 			elapsed := time.Since(startTime)
-			rate := float64(requestCount) / elapsed.Seconds()
+			rate := float64(tmdbAPI.Stats().Requests) / elapsed.Seconds()
 			log.Printf("Progress: %d/%d shows (%.1f req/s, %d synced, %d skipped, %d errors)",
 				i+1, len(shows), rate, synced, skipped, errors)
 		}
 
-		err := syncShow(db, s.id, s.imdbID, s.name)
+		err := syncShow(db, s.id, s.titleID, s.imdbID, s.name)
 		if err != nil {
 			if err.Error() == "not found on TMDB" {
 				skipped++
@@ -119,37 +103,57 @@ func main() {
 		} else {
 			synced++
 		}
-
-		// Small delay between shows
-		time.Sleep(50 * time.Millisecond)
 	}
 
 	elapsed := time.Since(startTime)
-	log.Printf("Done in %v. Synced: %d, Skipped: %d, Errors: %d, Total requests: %d",
-		elapsed.Round(time.Second), synced, skipped, errors, requestCount)
+	log.Printf("Done in %v. Synced: %d, Skipped: %d, Errors: %d",
+		elapsed.Round(time.Second), synced, skipped, errors)
+	log.Printf("TMDB client: %s", tmdbAPI.Stats())
 }
 
-func syncShow(db *sql.DB, showID int, imdbID, name string) error {
+// syncShow fetches a show's poster, metadata and episode stills, and records
+// each attempt in enrichment_status like the server's lazy fetch does.
+func syncShow(db *sql.DB, showID, titleID int, imdbID, name string) error {
 	// Get TMDB ID and poster
-	tmdbID, posterURL, origLang, releaseDate, originCountry, popularity, err := fetchTMDBShow(imdbID)
-	if err != nil {
+	tmdbID, posterPath, origLang, releaseDate, originCountry, popularity, err := fetchTMDBShow(imdbID)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+		recordStatus(db, enrichstatus.EntityTitle, []int{titleID}, enrichstatus.Error, err.Error())
 		return err
 	}
 
 	if tmdbID == 0 {
+		recordStatus(db, enrichstatus.EntityTitle, []int{titleID}, enrichstatus.NotFound, "")
 		return fmt.Errorf("not found on TMDB")
 	}
+	if posterPath != "" {
+		recordStatus(db, enrichstatus.EntityTitle, []int{titleID}, enrichstatus.Found, "")
+	} else {
+		recordStatus(db, enrichstatus.EntityTitle, []int{titleID}, enrichstatus.NotFound, "")
+	}
+
+	// Backdrop and logo come from the show's details
+	tv, err := tmdbAPI.TV(context.Background(), tmdbID)
+	if err != nil && !errors.Is(err, tmdb.ErrNotFound) {
+		return err
+	}
+	if err == nil {
+		db.Exec(`UPDATE titles SET
+				backdrop_path = COALESCE(NULLIF($2, ''), backdrop_path),
+				logo_path = COALESCE(NULLIF($3, ''), logo_path)
+			WHERE imdb_id = $1`,
+			imdbID, tv.BackdropPath, tv.LogoPath())
+	}
 
 	// Update show poster, original_language, release_date, tmdb_popularity, origin_country
-	if posterURL != "" {
+	if posterPath != "" {
 		_, err = db.Exec(`
-			UPDATE titles SET image_url = $1,
+			UPDATE titles SET image_url = $1, poster_path = $7,
 				original_language = COALESCE(NULLIF($3, ''), original_language),
 				release_date = CASE WHEN $4 = '' THEN release_date ELSE $4::date END,
 				tmdb_popularity = $5,
 				origin_country = COALESCE(NULLIF($6, ''), origin_country)
 			WHERE imdb_id = $2`,
-			posterURL, imdbID, origLang, releaseDate, popularity, originCountry)
+			tmdb.ImageURL("w500", posterPath), imdbID, origLang, releaseDate, popularity, originCountry, posterPath)
 		if err != nil {
 			return fmt.Errorf("updating poster: %w", err)
 		}
@@ -167,9 +171,9 @@ func syncShow(db *sql.DB, showID int, imdbID, name string) error {
 		}
 	}
 
-	// Get all episodes for this show
+	// Get all episodes for this show, grouped by season
 	rows, err := db.Query(`
-		SELECT e.id, ss.season, e.episode
+		SELECT ss.id, ss.season, e.id, e.episode
 		FROM show_episodes e
 		JOIN show_seasons ss ON e.season_id = ss.id
 		WHERE ss.show_id = $1
@@ -179,36 +183,80 @@ func syncShow(db *sql.DB, showID int, imdbID, name string) error {
 		return err
 	}
 
-	type ep struct {
-		id      int
-		season  int
-		episode int
+	type season struct {
+		id       int
+		number   int
+		episodes map[int]int // episode number -> show_episodes.id
 	}
-	var episodes []ep
+	var seasons []*season
 	for rows.Next() {
-		var e ep
-		rows.Scan(&e.id, &e.season, &e.episode)
-		episodes = append(episodes, e)
+		var seasonID, seasonNum, episodeID, episodeNum int
+		rows.Scan(&seasonID, &seasonNum, &episodeID, &episodeNum)
+		if len(seasons) == 0 || seasons[len(seasons)-1].id != seasonID {
+			seasons = append(seasons, &season{id: seasonID, number: seasonNum, episodes: map[int]int{}})
+		}
+		seasons[len(seasons)-1].episodes[episodeNum] = episodeID
 	}
 	rows.Close()
 
-	// Fetch episode data
-	for _, e := range episodes {
-		epData, err := fetchEpisodeData(tmdbID, e.season, e.episode)
-		if err != nil {
-			continue // Skip individual episode errors
+	// One request per season covers the season's metadata and all its
+	// episodes. Episode outcomes are collected by status, then recorded
+	status := map[string][]int{}
+	for _, sn := range seasons {
+		data, err := fetchSeasonData(tmdbID, sn.number)
+		if err != nil || data == nil {
+			// Skip individual season errors
+			st := enrichstatus.NotFound
+			if err != nil {
+				st = enrichstatus.Error
+			}
+			for _, id := range sn.episodes {
+				status[st] = append(status[st], id)
+			}
+			continue
 		}
 
-		if epData != nil {
+		db.Exec(`
+			UPDATE show_seasons SET
+				display_name = COALESCE(NULLIF($1, ''), display_name),
+				overview = COALESCE(NULLIF($2, ''), overview),
+				image_url = COALESCE($3, image_url),
+				poster_path = COALESCE($7, poster_path),
+				air_date = COALESCE($4::date, air_date),
+				episode_count = $5
+			WHERE id = $6
+		`, data.Name, data.Overview, imageURL(data.PosterPath), nullString(data.AirDate), len(data.Episodes), sn.id, nullString(data.PosterPath))
+
+		seen := map[int]bool{}
+		for _, ep := range data.Episodes {
+			episodeID, ok := sn.episodes[ep.EpisodeNumber]
+			if !ok {
+				continue
+			}
+			seen[episodeID] = true
+			if ep.StillPath != "" {
+				status[enrichstatus.Found] = append(status[enrichstatus.Found], episodeID)
+			} else {
+				status[enrichstatus.NotFound] = append(status[enrichstatus.NotFound], episodeID)
+			}
+			var runtime *int
+			if ep.Runtime > 0 {
+				runtime = &ep.Runtime
+			}
 			db.Exec(`
 				UPDATE show_episodes
-				SET image_url = $1, air_date = $2, runtime_minutes = $3
+				SET image_url = $1, still_path = $5, air_date = $2, runtime_minutes = $3
 				WHERE id = $4
-			`, epData.ImageURL, epData.AirDate, epData.Runtime, e.id)
+			`, imageURL(ep.StillPath), nullString(ep.AirDate), runtime, episodeID, nullString(ep.StillPath))
 		}
-
-		// Rate limit: ~40 req/sec, so sleep 25ms between requests
-		time.Sleep(25 * time.Millisecond)
+		for _, id := range sn.episodes {
+			if !seen[id] {
+				status[enrichstatus.NotFound] = append(status[enrichstatus.NotFound], id)
+			}
+		}
+	}
+	for st, ids := range status {
+		recordStatus(db, enrichstatus.EntityEpisode, ids, st, "")
 	}
 
 	// Mark episodes as checked so on-demand fetch doesn't redo this work
@@ -217,117 +265,61 @@ func syncShow(db *sql.DB, showID int, imdbID, name string) error {
 	return nil
 }
 
-func fetchTMDBShow(imdbID string) (tmdbID int, posterURL, originalLanguage, releaseDate, originCountry string, popularity float64, err error) {
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/find/%s?api_key=%s&external_source=imdb_id",
-		imdbID, apiKey,
-	)
-
-	requestCount++
-	resp, err := http.Get(url)
+func fetchTMDBShow(imdbID string) (tmdbID int, posterPath, originalLanguage, releaseDate, originCountry string, popularity float64, err error) {
+	result, err := tmdbAPI.Find(context.Background(), imdbID)
 	if err != nil {
 		return 0, "", "", "", "", 0, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 429 {
-		// Rate limited - wait and retry
-		log.Println("Rate limited, waiting 10 seconds...")
-		time.Sleep(10 * time.Second)
-		return fetchTMDBShow(imdbID)
-	}
-
-	if resp.StatusCode != 200 {
-		return 0, "", "", "", "", 0, fmt.Errorf("TMDB returned status %d", resp.StatusCode)
-	}
-
-	var result TMDBFindResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, "", "", "", "", 0, err
-	}
 
 	if len(result.TVResults) > 0 {
 		tv := result.TVResults[0]
-		posterURL := ""
-		if tv.PosterPath != "" {
-			posterURL = "https://image.tmdb.org/t/p/w500" + tv.PosterPath
-		}
 		oc := ""
 		if len(tv.OriginCountry) > 0 {
 			oc = tv.OriginCountry[0]
 		}
-		return tv.ID, posterURL, tv.OriginalLanguage, tv.FirstAirDate, oc, tv.Popularity, nil
+		return tv.ID, tv.PosterPath, tv.OriginalLanguage, tv.FirstAirDate, oc, tv.Popularity, nil
 	}
 
 	if len(result.MovieResults) > 0 {
 		mv := result.MovieResults[0]
-		posterURL := ""
-		if mv.PosterPath != "" {
-			posterURL = "https://image.tmdb.org/t/p/w500" + mv.PosterPath
-		}
 		oc := ""
 		if len(mv.OriginCountry) > 0 {
 			oc = mv.OriginCountry[0]
 		}
-		return mv.ID, posterURL, mv.OriginalLanguage, mv.ReleaseDate, oc, mv.Popularity, nil
+		return mv.ID, mv.PosterPath, mv.OriginalLanguage, mv.ReleaseDate, oc, mv.Popularity, nil
 	}
 
 	return 0, "", "", "", "", 0, nil
 }
 
-type EpisodeData struct {
-	ImageURL *string
-	AirDate  *string
-	Runtime  *int
-}
-
-func fetchEpisodeData(tmdbID, season, episode int) (*EpisodeData, error) {
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/tv/%d/season/%d/episode/%d?api_key=%s",
-		tmdbID, season, episode, apiKey,
-	)
-
-	requestCount++
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 429 {
-		// Rate limited - wait and retry
-		log.Println("Rate limited, waiting 10 seconds...")
-		time.Sleep(10 * time.Second)
-		return fetchEpisodeData(tmdbID, season, episode)
-	}
-
-	if resp.StatusCode == 404 {
-		return nil, nil // Episode not found on TMDB
+func recordStatus(db *sql.DB, entity string, ids []int, status, errMsg string) {
+	if err := enrichstatus.Record(db, entity, ids, status, errMsg); err != nil {
+		log.Printf("Failed to record %s enrichment status for %d %ss: %v", status, len(ids), entity, err)
 	}
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("TMDB returned status %d", resp.StatusCode)
-	}
-
-	var result TMDBEpisodeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	data := &EpisodeData{}
-
-	if result.StillPath != "" {
-		url := "https://image.tmdb.org/t/p/w500" + result.StillPath
-		data.ImageURL = &url
+// fetchSeasonData fetches a season with all its episodes. Returns nil, nil
+// if TMDB has no such season.
+func fetchSeasonData(tmdbID, season int) (*tmdb.Season, error) {
+	s, err := tmdbAPI.Season(context.Background(), tmdbID, season)
+	if errors.Is(err, tmdb.ErrNotFound) {
+		return nil, nil // Season not found on TMDB
 	}
+	return s, err
+}
 
-	if result.AirDate != "" {
-		data.AirDate = &result.AirDate
+// imageURL turns a TMDB file path into a w500 URL, or nil if there is none.
+func imageURL(path string) *string {
+	if path == "" {
+		return nil
 	}
+	url := tmdb.ImageURL("w500", path)
+	return &url
+}
 
-	if result.Runtime > 0 {
-		data.Runtime = &result.Runtime
+func nullString(s string) *string {
+	if s == "" {
+		return nil
 	}
-
-	return data, nil
+	return &s
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"mediacanon.org/backend/internal/dbtest"
+	"mediacanon.org/backend/internal/tmdb"
+	"mediacanon.org/backend/internal/tmdb/tmdbtest"
+)
+
+func TestTransientTMDBError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{tmdb.ErrNotFound, false},
+		{&tmdb.StatusError{Code: 401}, false},
+		{&tmdb.StatusError{Code: 429}, true},
+		{&tmdb.StatusError{Code: 503}, true},
+		{fmt.Errorf("tmdb: %w", errors.New("connection refused")), true},
+	} {
+		if got := transientTMDBError(tt.err); got != tt.want {
+			t.Errorf("transientTMDBError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// When TMDB stays unavailable the backfill stops at the first title, which
+// gets an error status, and every title keeps its flag for the next run.
+func TestTMDBBackfillStopsOnTransientError(t *testing.T) {
+	saved := db
+	db = dbtest.Open(t)
+	t.Cleanup(func() { db = saved })
+	fake := tmdbtest.New()
+	srv := fake.Start()
+	savedAPI := tmdbAPI
+	tmdbAPI = tmdbtest.NewClient(srv)
+	t.Cleanup(func() {
+		tmdbAPI = savedAPI
+		srv.Close()
+	})
+
+	// The most voted titles, so the backfill takes them first
+	var showID, movieID int
+	err := db.QueryRow(`INSERT INTO titles (type, display_name, imdb_id, tmdb_id, num_votes, needs_backfill_tmdb)
+		VALUES ('show', 'Test show', 'tt99903747', 1396, 2147483647, true) RETURNING id`).Scan(&showID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM titles WHERE id = $1`, showID) })
+	err = db.QueryRow(`INSERT INTO titles (type, display_name, imdb_id, tmdb_id, num_votes, needs_backfill_tmdb)
+		VALUES ('movie', 'Test movie', 'tt91375666', 27205, 2147483646, true) RETURNING id`).Scan(&movieID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM titles WHERE id = $1`, movieID)
+		db.Exec(`DELETE FROM enrichment_status WHERE entity_type = 'title' AND entity_id IN ($1, $2)`, showID, movieID)
+	})
+
+	fake.Fail(100, 503)
+	tmdbBackfillBatch()
+
+	// The first attempt and 3 retries, then nothing more
+	if n := fake.Count("/tv/1396"); n != 4 {
+		t.Errorf("%d show requests, want 4", n)
+	}
+	if n := len(fake.Requests()); n != 4 {
+		t.Errorf("%d requests in all, want 4: %v", n, fake.Requests())
+	}
+	for _, id := range []int{showID, movieID} {
+		var flagged bool
+		db.QueryRow(`SELECT needs_backfill_tmdb FROM titles WHERE id = $1`, id).Scan(&flagged)
+		if !flagged {
+			t.Errorf("title %d lost needs_backfill_tmdb", id)
+		}
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM enrichment_status WHERE entity_type = 'title' AND entity_id = $1`, showID).Scan(&status)
+	if status != "error" {
+		t.Errorf("show's status = %q, want error", status)
+	}
+	if err := db.QueryRow(`SELECT status FROM enrichment_status WHERE entity_type = 'title' AND entity_id = $1`, movieID).Scan(&status); err == nil {
+		t.Errorf("movie has status %q, want none (never attempted)", status)
+	}
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	namesURL      = "https://datasets.imdbws.com/name.basics.tsv.gz"
+	principalsURL = "https://datasets.imdbws.com/title.principals.tsv.gz"
+	crewURL       = "https://datasets.imdbws.com/title.crew.tsv.gz"
+)
+
+type PersonRecord struct {
+	ImdbID            string
+	DisplayName       string
+	BirthYear         *int
+	DeathYear         *int
+	PrimaryProfession string
+	KnownFor          string
+}
+
+func (p PersonRecord) equal(o PersonRecord) bool {
+	return p.ImdbID == o.ImdbID && p.DisplayName == o.DisplayName &&
+		intsEqual(p.BirthYear, o.BirthYear) && intsEqual(p.DeathYear, o.DeathYear) &&
+		p.PrimaryProfession == o.PrimaryProfession && p.KnownFor == o.KnownFor
+}
+
+// CreditRecord is one row of title_credits. Characters holds the character
+// names joined by charSep so records stay comparable with ==.
+type CreditRecord struct {
+	TitleID    int
+	Ordering   int
+	PersonID   int
+	Category   string
+	Job        string
+	Characters string
+}
+
+const charSep = "\x1f"
+
+type creditKey struct {
+	titleID  int
+	ordering int
+}
+
+// parsedCredit is a credit before its nconst is resolved to a people.id
+type parsedCredit struct {
+	CreditRecord
+	nconst string
+}
+
+func createCreditTables() error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS people (
+			id SERIAL PRIMARY KEY,
+			imdb_id VARCHAR(20) NOT NULL UNIQUE,
+			display_name VARCHAR(500) NOT NULL,
+			birth_year INTEGER,
+			death_year INTEGER,
+			primary_profession TEXT,
+			known_for TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS title_credits (
+			title_id INTEGER NOT NULL REFERENCES titles(id) ON DELETE CASCADE,
+			ordering INTEGER NOT NULL,
+			person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+			category VARCHAR(50) NOT NULL,
+			job TEXT,
+			characters TEXT[],
+			PRIMARY KEY (title_id, ordering)
+		);
+		CREATE INDEX IF NOT EXISTS idx_title_credits_person ON title_credits(person_id);
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS title_votes BIGINT`)
+	if err != nil {
+		return fmt.Errorf("create credit tables: %w", err)
+	}
+	return nil
+}
+
+// syncPersonVotes stores each person's total title votes in
+// people.title_votes, which people search ranks by, so the server needn't
+// sum every match's credits per query. Only changed rows are written.
+func syncPersonVotes() error {
+	res, err := db.Exec(`
+		UPDATE people p SET title_votes = v.votes
+		FROM (
+			SELECT p2.id, COALESCE(SUM(t.num_votes), 0) AS votes
+			FROM people p2
+			LEFT JOIN title_credits c ON c.person_id = p2.id
+			LEFT JOIN titles t ON t.id = c.title_id
+			GROUP BY p2.id
+		) v
+		WHERE p.id = v.id AND p.title_votes IS DISTINCT FROM v.votes`)
+	if err != nil {
+		return fmt.Errorf("person votes: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	log.Printf("Person vote totals updated for %d people", n)
+	return nil
+}
+
+// syncCredits imports title.principals.tsv.gz and title.crew.tsv.gz into
+// title_credits, and name.basics.tsv.gz into people for everyone credited.
+//
+// Principals are keyed by (title_id, ordering) as IMDb numbers them. The crew
+// file lists every director and writer but without billing, so directors and
+// writers missing from principals are appended after the title's last
+// principal, directors first, in crew-file order. Like syncAkas, unchanged
+// rows are skipped, changed rows upserted and vanished rows deleted.
+func syncCredits(namesFile, principalsFile, crewFile string) error {
+	if err := createCreditTables(); err != nil {
+		return err
+	}
+
+	imdbToTitleID := make(map[string]int)
+	rows, err := db.Query(`SELECT imdb_id, id FROM titles WHERE imdb_id IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var imdbID string
+		var titleID int
+		rows.Scan(&imdbID, &titleID)
+		imdbToTitleID[imdbID] = titleID
+	}
+	rows.Close()
+	log.Printf("Loaded %d imdb->title mappings", len(imdbToTitleID))
+
+	// Principals
+	log.Println("Scanning principals...")
+	var credits []parsedCredit
+	maxOrdering := make(map[int]int)
+	type billed struct {
+		titleID  int
+		nconst   string
+		category string
+	}
+	inPrincipals := make(map[billed]bool)
+	var ignored int64
+	err = scanTSV(principalsFile, 6, func(fields []string) {
+		titleID, ok := imdbToTitleID[fields[0]]
+		if !ok {
+			ignored++ // episodes, shorts, etc.
+			return
+		}
+		ordering, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return
+		}
+		credits = append(credits, parsedCredit{
+			CreditRecord: CreditRecord{
+				TitleID:    titleID,
+				Ordering:   ordering,
+				Category:   fields[3],
+				Job:        nullField(fields[4]),
+				Characters: parseCharacters(fields[5]),
+			},
+			nconst: fields[2],
+		})
+		if ordering > maxOrdering[titleID] {
+			maxOrdering[titleID] = ordering
+		}
+		inPrincipals[billed{titleID, fields[2], fields[3]}] = true
+		if len(credits)%1000000 == 0 {
+			log.Printf("Scanned %d principals, %d ignored...", len(credits), ignored)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Scanned %d principals, %d ignored", len(credits), ignored)
+
+	// Crew: directors and writers not already billed
+	log.Println("Scanning crew...")
+	var crewAdded int
+	err = scanTSV(crewFile, 3, func(fields []string) {
+		titleID, ok := imdbToTitleID[fields[0]]
+		if !ok {
+			return
+		}
+		for i, category := range []string{"director", "writer"} {
+			list := nullField(fields[i+1])
+			if list == "" {
+				continue
+			}
+			for _, nconst := range strings.Split(list, ",") {
+				if inPrincipals[billed{titleID, nconst, category}] {
+					continue
+				}
+				inPrincipals[billed{titleID, nconst, category}] = true
+				maxOrdering[titleID]++
+				credits = append(credits, parsedCredit{
+					CreditRecord: CreditRecord{TitleID: titleID, Ordering: maxOrdering[titleID], Category: category},
+					nconst:       nconst,
+				})
+				crewAdded++
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	inPrincipals = nil
+	log.Printf("Added %d directors/writers from crew", crewAdded)
+
+	// People: only those credited on a title we have
+	needed := make(map[string]bool)
+	for _, c := range credits {
+		needed[c.nconst] = true
+	}
+	personIDs, err := syncPeople(namesFile, needed)
+	if err != nil {
+		return err
+	}
+
+	// Diff credits against the table
+	log.Println("Loading existing credits...")
+	existing := make(map[creditKey]CreditRecord)
+	rows, err = db.Query(`SELECT title_id, ordering, person_id, category, COALESCE(job, ''), COALESCE(characters, '{}') FROM title_credits`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c CreditRecord
+		var chars []string
+		rows.Scan(&c.TitleID, &c.Ordering, &c.PersonID, &c.Category, &c.Job, pq.Array(&chars))
+		c.Characters = strings.Join(chars, charSep)
+		existing[creditKey{c.TitleID, c.Ordering}] = c
+	}
+	rows.Close()
+	log.Printf("Loaded %d existing credits", len(existing))
+
+	var toUpsert []CreditRecord
+	seen := make(map[creditKey]bool, len(existing))
+	var inserted, unchanged, unresolved int64
+	for _, pc := range credits {
+		personID, ok := personIDs[pc.nconst]
+		if !ok {
+			unresolved++ // person missing from name.basics
+			continue
+		}
+		rec := pc.CreditRecord
+		rec.PersonID = personID
+
+		key := creditKey{rec.TitleID, rec.Ordering}
+		seen[key] = true
+		if old, ok := existing[key]; ok {
+			if old == rec {
+				unchanged++
+				continue
+			}
+		} else {
+			inserted++
+		}
+		toUpsert = append(toUpsert, rec)
+	}
+
+	var toDelete []creditKey
+	for key := range existing {
+		if !seen[key] {
+			toDelete = append(toDelete, key)
+		}
+	}
+
+	log.Printf("Diff complete: %d credits (%d to insert, %d to update, %d to delete, %d unchanged), %d unresolved people",
+		len(credits), inserted, int64(len(toUpsert))-inserted, len(toDelete), unchanged, unresolved)
+
+	if len(toUpsert) > 0 {
+		if err := upsertCreditsBatched(toUpsert); err != nil {
+			return err
+		}
+	}
+	if len(toDelete) > 0 {
+		if err := deleteCreditsBatched(toDelete); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Credits done: %d upserted, %d deleted, %d unchanged", len(toUpsert), len(toDelete), unchanged)
+	return nil
+}
+
+// syncPeople upserts the needed people from name.basics.tsv.gz and returns
+// the nconst -> people.id map for them. People are never deleted: a person
+// dropped from every title we hold stays harmlessly unreferenced.
+func syncPeople(filepath string, needed map[string]bool) (map[string]int, error) {
+	log.Println("Loading existing people...")
+	existing := make(map[string]PersonRecord)
+	ids := make(map[string]int)
+	rows, err := db.Query(`SELECT id, imdb_id, display_name, birth_year, death_year, COALESCE(primary_profession, ''), COALESCE(known_for, '') FROM people`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var p PersonRecord
+		rows.Scan(&id, &p.ImdbID, &p.DisplayName, &p.BirthYear, &p.DeathYear, &p.PrimaryProfession, &p.KnownFor)
+		existing[p.ImdbID] = p
+		ids[p.ImdbID] = id
+	}
+	rows.Close()
+	log.Printf("Loaded %d existing people (%d needed)", len(existing), len(needed))
+
+	var toUpsert []PersonRecord
+	var scanned, inserted, unchanged int64
+	err = scanTSV(filepath, 6, func(fields []string) {
+		if !needed[fields[0]] {
+			return
+		}
+		p := PersonRecord{
+			ImdbID:            fields[0],
+			DisplayName:       fields[1],
+			BirthYear:         parseYear(fields[2]),
+			DeathYear:         parseYear(fields[3]),
+			PrimaryProfession: nullField(fields[4]),
+			KnownFor:          nullField(fields[5]),
+		}
+		scanned++
+		if old, ok := existing[p.ImdbID]; ok {
+			if old.equal(p) {
+				unchanged++
+				return
+			}
+		} else {
+			inserted++
+		}
+		toUpsert = append(toUpsert, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Scanned %d people: %d to insert, %d to update, %d unchanged",
+		scanned, inserted, int64(len(toUpsert))-inserted, unchanged)
+
+	if err := upsertPeopleBatched(toUpsert, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// upsertPeopleBatched writes people and records their ids in ids.
+func upsertPeopleBatched(records []PersonRecord, ids map[string]int) error {
+	for i := 0; i < len(records); i += batchSize {
+		end := i + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[i:end]
+
+		values := make([]string, len(batch))
+		args := make([]any, len(batch)*6)
+		for j, p := range batch {
+			base := j * 6
+			values[j] = fmt.Sprintf("($%d, $%d, $%d::int, $%d::int, NULLIF($%d, ''), NULLIF($%d, ''))", base+1, base+2, base+3, base+4, base+5, base+6)
+			args[base] = p.ImdbID
+			args[base+1] = p.DisplayName
+			args[base+2] = p.BirthYear
+			args[base+3] = p.DeathYear
+			args[base+4] = p.PrimaryProfession
+			args[base+5] = p.KnownFor
+		}
+
+		rows, err := db.Query(fmt.Sprintf(`
+			INSERT INTO people (imdb_id, display_name, birth_year, death_year, primary_profession, known_for)
+			VALUES %s
+			ON CONFLICT (imdb_id) DO UPDATE SET
+				display_name = EXCLUDED.display_name,
+				birth_year = EXCLUDED.birth_year,
+				death_year = EXCLUDED.death_year,
+				primary_profession = EXCLUDED.primary_profession,
+				known_for = EXCLUDED.known_for,
+				updated_at = NOW()
+			RETURNING imdb_id, id
+		`, strings.Join(values, ",")), args...)
+		if err != nil {
+			return fmt.Errorf("people upsert: %w", err)
+		}
+		for rows.Next() {
+			var imdbID string
+			var id int
+			rows.Scan(&imdbID, &id)
+			ids[imdbID] = id
+		}
+		rows.Close()
+
+		if (i/batchSize+1)%20 == 0 || end >= len(records) {
+			log.Printf("  upserted %d/%d people...", end, len(records))
+		}
+	}
+	return nil
+}
+
+func upsertCreditsBatched(records []CreditRecord) error {
+	for i := 0; i < len(records); i += batchSize {
+		end := i + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[i:end]
+
+		values := make([]string, len(batch))
+		args := make([]any, len(batch)*6)
+		for j, c := range batch {
+			base := j * 6
+			values[j] = fmt.Sprintf("($%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d::text[])", base+1, base+2, base+3, base+4, base+5, base+6)
+			args[base] = c.TitleID
+			args[base+1] = c.Ordering
+			args[base+2] = c.PersonID
+			args[base+3] = c.Category
+			args[base+4] = c.Job
+			var chars []string
+			if c.Characters != "" {
+				chars = strings.Split(c.Characters, charSep)
+			}
+			args[base+5] = pq.Array(chars)
+		}
+
+		_, err := db.Exec(fmt.Sprintf(`
+			INSERT INTO title_credits (title_id, ordering, person_id, category, job, characters)
+			VALUES %s
+			ON CONFLICT (title_id, ordering) DO UPDATE SET
+				person_id = EXCLUDED.person_id,
+				category = EXCLUDED.category,
+				job = EXCLUDED.job,
+				characters = EXCLUDED.characters
+		`, strings.Join(values, ",")), args...)
+		if err != nil {
+			return fmt.Errorf("credit upsert: %w", err)
+		}
+
+		if (i/batchSize+1)%20 == 0 || end >= len(records) {
+			log.Printf("  upserted %d/%d credits...", end, len(records))
+		}
+	}
+	return nil
+}
+
+func deleteCreditsBatched(keys []creditKey) error {
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[i:end]
+
+		values := make([]string, len(batch))
+		args := make([]any, len(batch)*2)
+		for j, k := range batch {
+			values[j] = fmt.Sprintf("($%d, $%d)", j*2+1, j*2+2)
+			args[j*2] = k.titleID
+			args[j*2+1] = k.ordering
+		}
+
+		_, err := db.Exec(fmt.Sprintf(`DELETE FROM title_credits WHERE (title_id, ordering) IN (%s)`, strings.Join(values, ",")), args...)
+		if err != nil {
+			return fmt.Errorf("credit delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanTSV calls fn with the fields of every data row of a gzipped IMDb TSV
+// file that has at least minFields columns.
+func scanTSV(filepath string, minFields int, fn func(fields []string)) error {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	scanner.Scan() // Skip header
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < minFields {
+			continue
+		}
+		fn(fields)
+	}
+	return scanner.Err()
+}
+
+// parseCharacters decodes the principals characters column, a JSON array
+// such as ["Neo"], into charSep-joined names.
+func parseCharacters(s string) string {
+	if s == "\\N" || s == "" {
+		return ""
+	}
+	var chars []string
+	if json.Unmarshal([]byte(s), &chars) != nil {
+		return ""
+	}
+	return strings.Join(chars, charSep)
+}
+
+func parseYear(s string) *int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
@@ -3,10 +3,11 @@ package main
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -21,12 +22,17 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+
+	"mediacanon.org/backend/internal/enrichstatus"
+	"mediacanon.org/backend/internal/showstatus"
+	"mediacanon.org/backend/internal/tmdb"
 )
 
 const (
 	basicsURL   = "https://datasets.imdbws.com/title.basics.tsv.gz"
 	episodesURL = "https://datasets.imdbws.com/title.episode.tsv.gz"
 	ratingsURL  = "https://datasets.imdbws.com/title.ratings.tsv.gz"
+	akasURL     = "https://datasets.imdbws.com/title.akas.tsv.gz"
 )
 
 var (
@@ -35,7 +41,7 @@ var (
 	batchSize     int
 	workers       int
 	titleGenres   = make(map[string][]string) // imdb_id -> genre names, populated during title scan
-	tmdbAPIKey    string
+	tmdbAPI       *tmdb.Client
 )
 
 // Existing data caches
@@ -52,6 +58,7 @@ type ExistingTitle struct {
 type ExistingEpisode struct {
 	ID          int
 	DisplayName string
+	ImdbID      string
 }
 
 type seasonKey struct {
@@ -81,6 +88,21 @@ type RatingRecord struct {
 	AverageRating float64
 }
 
+type AkaRecord struct {
+	TitleID    int
+	Ordering   int
+	Title      string
+	Region     string
+	Language   string
+	Types      string
+	IsOriginal bool
+}
+
+type akaKey struct {
+	titleID  int
+	ordering int
+}
+
 type EpisodeRecord struct {
 	ImdbID       string
 	ParentImdbID string
@@ -99,9 +121,16 @@ func main() {
 	genresFilter := flag.String("genres-filter", "", "Only export titles with these IMDb genres (comma-separated, e.g. 'Reality-TV,Game-Show')")
 	flag.IntVar(&batchSize, "batch", 5000, "Batch size for inserts")
 	flag.IntVar(&workers, "workers", 8, "Number of parallel workers")
+	popularityTop := flag.Int("popularity-top", 5000, "Titles whose TMDB popularity is refreshed daily (0 = none)")
 	flag.Parse()
 
-	tmdbAPIKey = os.Getenv("TMDB_API_KEY")
+	rps, burst := tmdb.RateFromEnv(25)
+	tmdbAPI = tmdb.New(tmdb.Config{
+		APIKey:            os.Getenv("TMDB_API_KEY"),
+		BaseURL:           os.Getenv("TMDB_BASE_URL"),
+		RequestsPerSecond: rps,
+		Burst:             burst,
+	})
 
 	var err error
 	db, err = sql.Open("postgres", *dsn)
@@ -158,6 +187,10 @@ func main() {
 	basicsFile := *downloadDir + "/title.basics.tsv.gz"
 	episodesFile := *downloadDir + "/title.episode.tsv.gz"
 	ratingsFile := *downloadDir + "/title.ratings.tsv.gz"
+	akasFile := *downloadDir + "/title.akas.tsv.gz"
+	namesFile := *downloadDir + "/name.basics.tsv.gz"
+	principalsFile := *downloadDir + "/title.principals.tsv.gz"
+	crewFile := *downloadDir + "/title.crew.tsv.gz"
 
 	log.Println("━━━ IMDb Import ━━━")
 
@@ -171,6 +204,14 @@ func main() {
 	if err := downloadFile(ratingsURL, ratingsFile); err != nil {
 		log.Fatal(err)
 	}
+	if err := downloadFile(akasURL, akasFile); err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range [][2]string{{namesURL, namesFile}, {principalsURL, principalsFile}, {crewURL, crewFile}} {
+		if err := downloadFile(d[0], d[1]); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Compute combined hash of all 3 files
 	log.Println("[1.2] Checking file hashes...")
@@ -207,6 +248,9 @@ func main() {
 		if err := syncEpisodes(episodesFile); err != nil {
 			log.Fatal(err)
 		}
+		if err := syncAbsoluteOrderings(); err != nil {
+			log.Fatal(err)
+		}
 
 		log.Println("[1.6] Syncing ratings...")
 		if err := syncRatings(ratingsFile); err != nil {
@@ -218,12 +262,57 @@ func main() {
 		log.Println("Import complete, hash saved")
 	}
 
+	// Akas are hashed separately: the file is large and changes independently,
+	// but a title import must still run akas so new titles pick up their aliases.
+	akasHash, err := hashFiles(akasFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *forceImdb || imdbChanged || akasHash != getSyncState("imdb_akas_hash") {
+		log.Println("[1.7] Syncing alternate titles (akas)...")
+		if err := syncAkas(akasFile); err != nil {
+			log.Fatal(err)
+		}
+		setSyncState("imdb_akas_hash", akasHash)
+	} else {
+		log.Printf("Akas file unchanged (hash: %s…), skipping", akasHash[:12])
+	}
+
+	// Cast and crew: same rule as akas, hashed over all three people files
+	creditsHash, err := hashFiles(namesFile, principalsFile, crewFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	creditsChanged := *forceImdb || imdbChanged || creditsHash != getSyncState("imdb_credits_hash")
+	if creditsChanged {
+		log.Println("[1.8] Syncing people and credits...")
+		if err := syncCredits(namesFile, principalsFile, crewFile); err != nil {
+			log.Fatal(err)
+		}
+		setSyncState("imdb_credits_hash", creditsHash)
+	} else {
+		log.Printf("Credit files unchanged (hash: %s…), skipping", creditsHash[:12])
+	}
+
+	// Vote totals follow both ratings and credits; a NULL total is a person
+	// added since, or a database from before the column
+	var missingVotes bool
+	db.QueryRow(`SELECT EXISTS (SELECT 1 FROM people WHERE title_votes IS NULL)`).Scan(&missingVotes)
+	if creditsChanged || missingVotes {
+		log.Println("[1.9] Updating person vote totals...")
+		if err := syncPersonVotes(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// ── Section 2: TMDB Backfill ─────────────────────────────────────
-	if tmdbAPIKey == "" {
+	if !tmdbAPI.Enabled() {
 		log.Println("━━━ TMDB Backfill ━━━")
 		log.Println("Skipping: TMDB_API_KEY not set")
 	} else {
 		log.Println("━━━ TMDB Backfill ━━━")
+		flagTMDBChanges()
+		flagPopularTitles(*popularityTop)
 		tmdbBackfillBatch()
 	}
 
@@ -854,6 +943,15 @@ func syncGenres() error {
 }
 
 func syncEpisodes(filepath string) error {
+	_, err := db.Exec(`
+		ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS imdb_id VARCHAR(20);
+		ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS num_votes INTEGER;
+		ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS average_rating REAL;
+		CREATE INDEX IF NOT EXISTS idx_show_episodes_imdb_id ON show_episodes(imdb_id)`)
+	if err != nil {
+		return fmt.Errorf("add show_episodes imdb columns: %w", err)
+	}
+
 	// Build show imdb_id -> show_id cache
 	showCache := make(map[string]int)
 	rows, err := db.Query(`SELECT t.imdb_id, s.id FROM shows s JOIN titles t ON s.title_id = t.id`)
@@ -887,17 +985,19 @@ func syncEpisodes(filepath string) error {
 	// Load existing episodes
 	log.Println("Loading existing episodes...")
 	existingEpisodes := make(map[episodeKey]ExistingEpisode)
-	rows, err = db.Query(`SELECT id, season_id, episode, display_name FROM show_episodes`)
+	rows, err = db.Query(`SELECT id, season_id, episode, display_name, COALESCE(imdb_id, '') FROM show_episodes`)
 	if err != nil {
 		return err
 	}
 	for rows.Next() {
 		var id, seasonID, episode int
 		var displayName sql.NullString
-		rows.Scan(&id, &seasonID, &episode, &displayName)
+		var imdbID string
+		rows.Scan(&id, &seasonID, &episode, &displayName, &imdbID)
 		existingEpisodes[episodeKey{seasonID, episode}] = ExistingEpisode{
 			ID:          id,
 			DisplayName: displayName.String,
+			ImdbID:      imdbID,
 		}
 	}
 	rows.Close()
@@ -1019,10 +1119,12 @@ func syncEpisodes(filepath string) error {
 		SeasonID    int
 		Episode     int
 		DisplayName *string
+		ImdbID      string
 	}
 	type EpisodeUpdate struct {
 		ID          int
 		DisplayName string
+		ImdbID      string
 	}
 
 	var toInsert []EpisodeInsert
@@ -1075,11 +1177,16 @@ func syncEpisodes(filepath string) error {
 
 		key := episodeKey{seasonID, episode}
 		if existing, ok := existingEpisodes[key]; ok {
-			// Episode exists - check if display_name needs update
-			if displayName != "" && existing.DisplayName != displayName {
+			// Episode exists - check if display_name or imdb_id needs update
+			nameChanged := displayName != "" && existing.DisplayName != displayName
+			if nameChanged || existing.ImdbID != episodeImdbID {
+				if !nameChanged {
+					displayName = existing.DisplayName
+				}
 				toUpdate = append(toUpdate, EpisodeUpdate{
 					ID:          existing.ID,
 					DisplayName: displayName,
+					ImdbID:      episodeImdbID,
 				})
 			} else {
 				unchanged++
@@ -1094,6 +1201,7 @@ func syncEpisodes(filepath string) error {
 				SeasonID:    seasonID,
 				Episode:     episode,
 				DisplayName: dn,
+				ImdbID:      episodeImdbID,
 			})
 		}
 
@@ -1117,17 +1225,18 @@ func syncEpisodes(filepath string) error {
 			batch := toInsert[i:end]
 
 			values := make([]string, len(batch))
-			args := make([]any, len(batch)*3)
+			args := make([]any, len(batch)*4)
 			for j, ep := range batch {
-				base := j * 3
-				values[j] = fmt.Sprintf("($%d, $%d, $%d)", base+1, base+2, base+3)
+				base := j * 4
+				values[j] = fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4)
 				args[base] = ep.SeasonID
 				args[base+1] = ep.Episode
 				args[base+2] = ep.DisplayName
+				args[base+3] = ep.ImdbID
 			}
 
 			_, err := db.Exec(fmt.Sprintf(`
-				INSERT INTO show_episodes (season_id, episode, display_name)
+				INSERT INTO show_episodes (season_id, episode, display_name, imdb_id)
 				VALUES %s
 			`, strings.Join(values, ",")), args...)
 			if err != nil {
@@ -1142,7 +1251,7 @@ func syncEpisodes(filepath string) error {
 
 	// Update episodes with changed display names
 	if len(toUpdate) > 0 {
-		log.Printf("Updating %d episodes with new display names...", len(toUpdate))
+		log.Printf("Updating %d episodes with new display names or IMDb ids...", len(toUpdate))
 		for i := 0; i < len(toUpdate); i += batchSize {
 			end := i + batchSize
 			if end > len(toUpdate) {
@@ -1151,22 +1260,25 @@ func syncEpisodes(filepath string) error {
 			batch := toUpdate[i:end]
 
 			// Build UPDATE with CASE
-			args := make([]any, 0, len(batch)*2)
+			args := make([]any, 0, len(batch)*3)
 			cases := make([]string, len(batch))
+			imdbCases := make([]string, len(batch))
 			idPlaceholders := make([]string, len(batch))
 
 			for j, ep := range batch {
-				base := j * 2
+				base := j * 3
 				idPlaceholders[j] = fmt.Sprintf("$%d", base+1)
 				cases[j] = fmt.Sprintf("WHEN id = $%d THEN $%d", base+1, base+2)
-				args = append(args, ep.ID, ep.DisplayName)
+				imdbCases[j] = fmt.Sprintf("WHEN id = $%d THEN $%d", base+1, base+3)
+				args = append(args, ep.ID, ep.DisplayName, ep.ImdbID)
 			}
 
 			_, err := db.Exec(fmt.Sprintf(`
 				UPDATE show_episodes SET
-					display_name = CASE %s END
+					display_name = NULLIF(CASE %s END, ''),
+					imdb_id = CASE %s END
 				WHERE id IN (%s)
-			`, strings.Join(cases, " "), strings.Join(idPlaceholders, ",")), args...)
+			`, strings.Join(cases, " "), strings.Join(imdbCases, " "), strings.Join(idPlaceholders, ",")), args...)
 			if err != nil {
 				return fmt.Errorf("episode update: %w", err)
 			}
@@ -1205,6 +1317,21 @@ func syncRatings(filepath string) error {
 	rows.Close()
 	log.Printf("Loaded %d existing ratings", len(existingRatings))
 
+	// Episode ratings go to show_episodes, keyed by the tconst syncEpisodes stored
+	existingEpisodeRatings := make(map[string]ExistingRating)
+	rows, err = db.Query(`SELECT imdb_id, COALESCE(num_votes, 0), COALESCE(average_rating, 0) FROM show_episodes WHERE imdb_id IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var imdbID string
+		var r ExistingRating
+		rows.Scan(&imdbID, &r.NumVotes, &r.AverageRating)
+		existingEpisodeRatings[imdbID] = r
+	}
+	rows.Close()
+	log.Printf("Loaded %d existing episode ratings", len(existingEpisodeRatings))
+
 	f, err := os.Open(filepath)
 	if err != nil {
 		return err
@@ -1221,8 +1348,8 @@ func syncRatings(filepath string) error {
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
 	scanner.Scan() // Skip header: tconst, averageRating, numVotes
 
-	var batch []RatingRecord
-	var scanned, updated, unchanged int64
+	var batch, episodeBatch []RatingRecord
+	var scanned, updated, episodesUpdated, unchanged int64
 
 	for scanner.Scan() {
 		fields := strings.Split(scanner.Text(), "\t")
@@ -1242,6 +1369,23 @@ func syncRatings(filepath string) error {
 
 		scanned++
 
+		if existing, ok := existingEpisodeRatings[imdbID]; ok {
+			if existing.NumVotes == numVotes && existing.AverageRating == float64(float32(averageRating)) {
+				unchanged++
+				continue
+			}
+			episodeBatch = append(episodeBatch, RatingRecord{imdbID, numVotes, averageRating})
+			if len(episodeBatch) >= batchSize {
+				n, err := updateRatingsBatch("show_episodes", episodeBatch)
+				if err != nil {
+					return err
+				}
+				episodesUpdated += n
+				episodeBatch = episodeBatch[:0]
+			}
+			continue
+		}
+
 		// Skip if unchanged
 		if existing, ok := existingRatings[imdbID]; ok {
 			if existing.NumVotes == numVotes && existing.AverageRating == float64(float32(averageRating)) {
@@ -1253,7 +1397,7 @@ func syncRatings(filepath string) error {
 		batch = append(batch, RatingRecord{imdbID, numVotes, averageRating})
 
 		if len(batch) >= batchSize {
-			n, err := updateRatingsBatch(batch)
+			n, err := updateRatingsBatch("titles", batch)
 			if err != nil {
 				return err
 			}
@@ -1268,18 +1412,27 @@ func syncRatings(filepath string) error {
 
 	// Flush remaining
 	if len(batch) > 0 {
-		n, err := updateRatingsBatch(batch)
+		n, err := updateRatingsBatch("titles", batch)
 		if err != nil {
 			return err
 		}
 		updated += n
 	}
+	if len(episodeBatch) > 0 {
+		n, err := updateRatingsBatch("show_episodes", episodeBatch)
+		if err != nil {
+			return err
+		}
+		episodesUpdated += n
+	}
 
-	log.Printf("Ratings complete: scanned %d, updated %d, unchanged %d", scanned, updated, unchanged)
+	log.Printf("Ratings complete: scanned %d, updated %d titles and %d episodes, unchanged %d", scanned, updated, episodesUpdated, unchanged)
 	return scanner.Err()
 }
 
-func updateRatingsBatch(records []RatingRecord) (int64, error) {
+// updateRatingsBatch sets num_votes and average_rating by imdb_id in table
+// (titles or show_episodes).
+func updateRatingsBatch(table string, records []RatingRecord) (int64, error) {
 	args := make([]any, 0, len(records)*3)
 	votesCases := make([]string, len(records))
 	ratingCases := make([]string, len(records))
@@ -1294,11 +1447,11 @@ func updateRatingsBatch(records []RatingRecord) (int64, error) {
 	}
 
 	result, err := db.Exec(fmt.Sprintf(`
-		UPDATE titles SET
+		UPDATE %s SET
 			num_votes = CASE %s END,
 			average_rating = CASE %s END
 		WHERE imdb_id IN (%s)
-	`, strings.Join(votesCases, " "), strings.Join(ratingCases, " "), strings.Join(idPlaceholders, ",")), args...)
+	`, table, strings.Join(votesCases, " "), strings.Join(ratingCases, " "), strings.Join(idPlaceholders, ",")), args...)
 	if err != nil {
 		return 0, fmt.Errorf("ratings update: %w", err)
 	}
@@ -1307,47 +1460,234 @@ func updateRatingsBatch(records []RatingRecord) (int64, error) {
 	return n, nil
 }
 
+// syncAkas imports title.akas.tsv.gz into title_akas for movies and shows we
+// already have. Rows are keyed by (title_id, ordering); new and changed rows
+// are upserted, and rows IMDb no longer lists are deleted.
+func syncAkas(filepath string) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS title_akas (
+		title_id INTEGER NOT NULL REFERENCES titles(id) ON DELETE CASCADE,
+		ordering INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		region VARCHAR(10),
+		language VARCHAR(10),
+		types TEXT,
+		is_original BOOLEAN NOT NULL DEFAULT FALSE,
+		PRIMARY KEY (title_id, ordering)
+	)`)
+	if err != nil {
+		return fmt.Errorf("create title_akas table: %w", err)
+	}
+
+	imdbToTitleID := make(map[string]int)
+	rows, err := db.Query(`SELECT imdb_id, id FROM titles WHERE imdb_id IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var imdbID string
+		var titleID int
+		rows.Scan(&imdbID, &titleID)
+		imdbToTitleID[imdbID] = titleID
+	}
+	rows.Close()
+	log.Printf("Loaded %d imdb->title mappings", len(imdbToTitleID))
+
+	log.Println("Loading existing akas...")
+	existing := make(map[akaKey]AkaRecord)
+	rows, err = db.Query(`SELECT title_id, ordering, title, COALESCE(region, ''), COALESCE(language, ''), COALESCE(types, ''), is_original FROM title_akas`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var a AkaRecord
+		rows.Scan(&a.TitleID, &a.Ordering, &a.Title, &a.Region, &a.Language, &a.Types, &a.IsOriginal)
+		existing[akaKey{a.TitleID, a.Ordering}] = a
+	}
+	rows.Close()
+	log.Printf("Loaded %d existing akas", len(existing))
+
+	f, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	scanner.Scan() // Skip header: titleId, ordering, title, region, language, types, attributes, isOriginalTitle
+
+	var toUpsert []AkaRecord
+	seen := make(map[akaKey]bool, len(existing))
+	var scanned, inserted, unchanged, ignored int64
+
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 8 {
+			continue
+		}
+
+		titleID, ok := imdbToTitleID[fields[0]]
+		if !ok {
+			ignored++ // episodes, shorts, etc.
+			continue
+		}
+		ordering, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		rec := AkaRecord{
+			TitleID:    titleID,
+			Ordering:   ordering,
+			Title:      fields[2],
+			Region:     nullField(fields[3]),
+			Language:   nullField(fields[4]),
+			Types:      strings.ReplaceAll(nullField(fields[5]), "\x02", ","),
+			IsOriginal: fields[7] == "1",
+		}
+		scanned++
+
+		key := akaKey{titleID, ordering}
+		seen[key] = true
+		if old, ok := existing[key]; ok {
+			if old == rec {
+				unchanged++
+				continue
+			}
+		} else {
+			inserted++
+		}
+		toUpsert = append(toUpsert, rec)
+
+		if scanned%1000000 == 0 {
+			log.Printf("Scanned %d akas: %d unchanged, %d to upsert, %d ignored...", scanned, unchanged, len(toUpsert), ignored)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var toDelete []akaKey
+	for key := range existing {
+		if !seen[key] {
+			toDelete = append(toDelete, key)
+		}
+	}
+
+	log.Printf("Scan complete: %d akas (%d to insert, %d to update, %d to delete, %d unchanged), %d ignored",
+		scanned, inserted, int64(len(toUpsert))-inserted, len(toDelete), unchanged, ignored)
+
+	if len(toUpsert) > 0 {
+		if err := upsertAkasBatched(toUpsert); err != nil {
+			return err
+		}
+	}
+	if len(toDelete) > 0 {
+		if err := deleteAkasBatched(toDelete); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Akas done: %d upserted, %d deleted, %d unchanged", len(toUpsert), len(toDelete), unchanged)
+	return nil
+}
+
+// nullField maps IMDb's \N placeholder to an empty string.
+func nullField(s string) string {
+	if s == "\\N" {
+		return ""
+	}
+	return s
+}
+
+func upsertAkasBatched(records []AkaRecord) error {
+	for i := 0; i < len(records); i += batchSize {
+		end := i + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := records[i:end]
+
+		values := make([]string, len(batch))
+		args := make([]any, len(batch)*7)
+		for j, a := range batch {
+			base := j * 7
+			values[j] = fmt.Sprintf("($%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d)", base+1, base+2, base+3, base+4, base+5, base+6, base+7)
+			args[base] = a.TitleID
+			args[base+1] = a.Ordering
+			args[base+2] = a.Title
+			args[base+3] = a.Region
+			args[base+4] = a.Language
+			args[base+5] = a.Types
+			args[base+6] = a.IsOriginal
+		}
+
+		_, err := db.Exec(fmt.Sprintf(`
+			INSERT INTO title_akas (title_id, ordering, title, region, language, types, is_original)
+			VALUES %s
+			ON CONFLICT (title_id, ordering) DO UPDATE SET
+				title = EXCLUDED.title,
+				region = EXCLUDED.region,
+				language = EXCLUDED.language,
+				types = EXCLUDED.types,
+				is_original = EXCLUDED.is_original
+		`, strings.Join(values, ",")), args...)
+		if err != nil {
+			return fmt.Errorf("aka upsert: %w", err)
+		}
+
+		if (i/batchSize+1)%20 == 0 || end >= len(records) {
+			log.Printf("  upserted %d/%d akas...", end, len(records))
+		}
+	}
+	return nil
+}
+
+func deleteAkasBatched(keys []akaKey) error {
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[i:end]
+
+		values := make([]string, len(batch))
+		args := make([]any, len(batch)*2)
+		for j, k := range batch {
+			values[j] = fmt.Sprintf("($%d, $%d)", j*2+1, j*2+2)
+			args[j*2] = k.titleID
+			args[j*2+1] = k.ordering
+		}
+
+		_, err := db.Exec(fmt.Sprintf(`DELETE FROM title_akas WHERE (title_id, ordering) IN (%s)`, strings.Join(values, ",")), args...)
+		if err != nil {
+			return fmt.Errorf("aka delete: %w", err)
+		}
+	}
+	return nil
+}
+
 // Custom genre names (arbitrary thematic tags assigned during review)
 var customGenreNames = []string{"Dating", "Cooking"}
 
 // tmdbBackfill fetches origin_country (and other metadata) from TMDB for a title missing it.
 // Returns the origin_country code, or "" if unavailable.
 func tmdbBackfill(titleID int, imdbID, titleType string) string {
-	if tmdbAPIKey == "" || imdbID == "" {
+	if !tmdbAPI.Enabled() || imdbID == "" {
 		return ""
 	}
+	ctx := context.Background()
 
-	url := fmt.Sprintf("https://api.themoviedb.org/3/find/%s?api_key=%s&external_source=imdb_id", imdbID, tmdbAPIKey)
-	resp, err := http.Get(url)
+	result, err := tmdbAPI.Find(ctx, imdbID)
 	if err != nil {
 		return ""
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return ""
-	}
-
-	var result struct {
-		TVResults []struct {
-			ID               int      `json:"id"`
-			PosterPath       string   `json:"poster_path"`
-			OriginalLanguage string   `json:"original_language"`
-			FirstAirDate     string   `json:"first_air_date"`
-			Popularity       float64  `json:"popularity"`
-			OriginCountry    []string `json:"origin_country"`
-		} `json:"tv_results"`
-		MovieResults []struct {
-			ID               int      `json:"id"`
-			PosterPath       string   `json:"poster_path"`
-			OriginalLanguage string   `json:"original_language"`
-			ReleaseDate      string   `json:"release_date"`
-			Popularity       float64  `json:"popularity"`
-			OriginCountry    []string `json:"origin_country"`
-		} `json:"movie_results"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return ""
-	}
 
 	var originCountry, origLang, releaseDate, posterPath string
 	var tmdbID int
@@ -1380,35 +1720,17 @@ func tmdbBackfill(titleID int, imdbID, titleType string) string {
 	}
 
 	// Find API doesn't return origin_country for movies — fetch from movie details
-	if originCountry == "" && tmdbID != 0 {
-		detailURL := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?api_key=%s", tmdbID, tmdbAPIKey)
+	if originCountry == "" {
 		if titleType == "show" {
-			detailURL = fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?api_key=%s", tmdbID, tmdbAPIKey)
-		}
-		if dresp, err := http.Get(detailURL); err == nil {
-			defer dresp.Body.Close()
-			if dresp.StatusCode == 200 {
-				var detail struct {
-					OriginCountry     []string `json:"origin_country"`
-					ProductionCountries []struct {
-						ISO string `json:"iso_3166_1"`
-					} `json:"production_countries"`
-				}
-				if json.NewDecoder(dresp.Body).Decode(&detail) == nil {
-					if len(detail.OriginCountry) > 0 {
-						originCountry = detail.OriginCountry[0]
-					} else if len(detail.ProductionCountries) > 0 {
-						originCountry = detail.ProductionCountries[0].ISO
-					}
-				}
+			if tv, err := tmdbAPI.TV(ctx, tmdbID); err == nil {
+				originCountry = tv.Country()
 			}
+		} else if mv, err := tmdbAPI.Movie(ctx, tmdbID); err == nil {
+			originCountry = mv.Country()
 		}
 	}
 
-	imageURL := ""
-	if posterPath != "" {
-		imageURL = "https://image.tmdb.org/t/p/w500" + posterPath
-	}
+	imageURL := tmdb.ImageURL("w500", posterPath)
 
 	db.Exec(`UPDATE titles SET
 		tmdb_id = COALESCE(tmdb_id, $1),
@@ -1423,13 +1745,27 @@ func tmdbBackfill(titleID int, imdbID, titleType string) string {
 	return originCountry
 }
 
+// transientTMDBError reports an error worth retrying on a later run: rate
+// limiting, a TMDB outage or a network failure that outlasted the client's
+// own retries, as opposed to TMDB answering that the title doesn't exist.
+func transientTMDBError(err error) bool {
+	if err == nil || errors.Is(err, tmdb.ErrNotFound) {
+		return false
+	}
+	var se *tmdb.StatusError
+	if errors.As(err, &se) {
+		return se.Code == 429 || se.Code >= 500
+	}
+	return true
+}
+
 // tmdbBackfillBatch processes all titles with needs_backfill_tmdb=true in batches.
 // For each title, calls TMDB Details API to fill origin_country, image, popularity, etc.
+// Rate limiting and retries are tmdbAPI's; if TMDB stays unavailable the
+// backfill stops and the remaining titles keep their flag for the next run.
 func tmdbBackfillBatch() {
 	const batchLimit = 100
-	// ~40 req/sec to stay under TMDB rate limit
-	rateLimiter := time.NewTicker(25 * time.Millisecond)
-	defer rateLimiter.Stop()
+	ctx := context.Background()
 
 	total := 0
 	db.QueryRow(`SELECT COUNT(*) FROM titles WHERE needs_backfill_tmdb = true`).Scan(&total)
@@ -1437,12 +1773,13 @@ func tmdbBackfillBatch() {
 		log.Println("No titles need TMDB backfill")
 		return
 	}
-	log.Printf("[2.1] %d titles need TMDB backfill, processing in batches of %d...", total, batchLimit)
+	log.Printf("[2.3] %d titles need TMDB backfill, processing in batches of %d...", total, batchLimit)
 
 	processed := 0
 	updated := 0
 	batchNum := 0
 
+batches:
 	for {
 		batchNum++
 		rows, err := db.Query(`
@@ -1490,96 +1827,65 @@ func tmdbBackfillBatch() {
 
 			// Resolve TMDB ID via Find API if needed
 			if tmdbID == 0 {
-				<-rateLimiter.C
-				findURL := fmt.Sprintf("https://api.themoviedb.org/3/find/%s?api_key=%s&external_source=imdb_id", *r.ImdbID, tmdbAPIKey)
-				if resp, err := http.Get(findURL); err == nil {
-					if resp.StatusCode == 200 {
-						var result struct {
-							TVResults    []struct{ ID int `json:"id"` } `json:"tv_results"`
-							MovieResults []struct{ ID int `json:"id"` } `json:"movie_results"`
-						}
-						if json.NewDecoder(resp.Body).Decode(&result) == nil {
-							if r.Type == "show" && len(result.TVResults) > 0 {
-								tmdbID = result.TVResults[0].ID
-							} else if r.Type == "movie" && len(result.MovieResults) > 0 {
-								tmdbID = result.MovieResults[0].ID
-							} else if len(result.MovieResults) > 0 {
-								tmdbID = result.MovieResults[0].ID
-							} else if len(result.TVResults) > 0 {
-								tmdbID = result.TVResults[0].ID
-							}
-						}
+				result, err := tmdbAPI.Find(ctx, *r.ImdbID)
+				if transientTMDBError(err) {
+					recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.Error, err.Error())
+					log.Printf("    TMDB unavailable (%v), stopping backfill; remaining titles retry next run", err)
+					break batches
+				}
+				if err == nil {
+					if r.Type == "show" && len(result.TVResults) > 0 {
+						tmdbID = result.TVResults[0].ID
+					} else if r.Type == "movie" && len(result.MovieResults) > 0 {
+						tmdbID = result.MovieResults[0].ID
+					} else if len(result.MovieResults) > 0 {
+						tmdbID = result.MovieResults[0].ID
+					} else if len(result.TVResults) > 0 {
+						tmdbID = result.TVResults[0].ID
 					}
-					resp.Body.Close()
 				}
 			}
 
 			if tmdbID == 0 {
+				recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.NotFound, "")
 				db.Exec(`UPDATE titles SET needs_backfill_tmdb = false WHERE id = $1`, r.ID)
 				processed++
 				continue
 			}
 
 			// Call TMDB Details API
-			<-rateLimiter.C
-			detailURL := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?api_key=%s", tmdbID, tmdbAPIKey)
+			var detail tmdb.Details
+			var releaseDate string
+			var runtime float64
+			var tv *tmdb.TV
 			if r.Type == "show" {
-				detailURL = fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?api_key=%s", tmdbID, tmdbAPIKey)
+				if tv, err = tmdbAPI.TV(ctx, tmdbID); err == nil {
+					detail, releaseDate = tv.Details, tv.FirstAirDate
+				}
+			} else {
+				var mv *tmdb.Movie
+				if mv, err = tmdbAPI.Movie(ctx, tmdbID); err == nil {
+					detail, releaseDate, runtime = mv.Details, mv.ReleaseDate, mv.Runtime
+				}
 			}
-
-			dresp, err := http.Get(detailURL)
-			if err != nil {
-				log.Printf("    TMDB details fetch error for %d: %v", r.ID, err)
-				db.Exec(`UPDATE titles SET needs_backfill_tmdb = false WHERE id = $1`, r.ID)
-				processed++
-				continue
+			if transientTMDBError(err) {
+				recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.Error, err.Error())
+				log.Printf("    TMDB unavailable (%v), stopping backfill; remaining titles retry next run", err)
+				break batches
 			}
-
-			if dresp.StatusCode != 200 {
-				if dresp.StatusCode == 429 {
-					log.Printf("    TMDB rate limited, sleeping 5s...")
-					dresp.Body.Close()
-					time.Sleep(5 * time.Second)
-					processed++
-					continue // don't clear flag, retry next batch
+			if err != nil {
+				if errors.Is(err, tmdb.ErrNotFound) {
+					recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.NotFound, "")
+				} else {
+					recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.Error, err.Error())
 				}
-				dresp.Body.Close()
 				db.Exec(`UPDATE titles SET needs_backfill_tmdb = false WHERE id = $1`, r.ID)
 				processed++
 				continue
 			}
 
-			var detail struct {
-				PosterPath          string   `json:"poster_path"`
-				OriginalLanguage    string   `json:"original_language"`
-				ReleaseDate         string   `json:"release_date"`
-				FirstAirDate        string   `json:"first_air_date"`
-				Popularity          float64  `json:"popularity"`
-				OriginCountry       []string `json:"origin_country"`
-				ProductionCountries []struct {
-					ISO string `json:"iso_3166_1"`
-				} `json:"production_countries"`
-				Runtime float64 `json:"runtime"`
-			}
-			json.NewDecoder(dresp.Body).Decode(&detail)
-			dresp.Body.Close()
-
-			originCountry := ""
-			if len(detail.OriginCountry) > 0 {
-				originCountry = detail.OriginCountry[0]
-			} else if len(detail.ProductionCountries) > 0 {
-				originCountry = detail.ProductionCountries[0].ISO
-			}
-
-			releaseDate := detail.ReleaseDate
-			if releaseDate == "" {
-				releaseDate = detail.FirstAirDate
-			}
-
-			imageURL := ""
-			if detail.PosterPath != "" {
-				imageURL = "https://image.tmdb.org/t/p/w500" + detail.PosterPath
-			}
+			originCountry := detail.Country()
+			imageURL := tmdb.ImageURL("w500", detail.PosterPath)
 
 			_, err = db.Exec(`UPDATE titles SET
 				tmdb_id = $1,
@@ -1589,21 +1895,42 @@ func tmdbBackfillBatch() {
 				tmdb_popularity = CASE WHEN $5::real = 0 THEN tmdb_popularity ELSE $5::real END,
 				origin_country = COALESCE(NULLIF($6, ''), origin_country),
 				runtime_minutes = CASE WHEN $7::int = 0 THEN runtime_minutes ELSE $7::int END,
+				poster_path = COALESCE(NULLIF($9, ''), poster_path),
+				backdrop_path = COALESCE(NULLIF($10, ''), backdrop_path),
+				logo_path = COALESCE(NULLIF($11, ''), logo_path),
 				needs_backfill_tmdb = false
 				WHERE id = $8`,
 				tmdbID, imageURL, detail.OriginalLanguage, releaseDate,
-				detail.Popularity, originCountry, int(detail.Runtime), r.ID)
+				detail.Popularity, originCountry, int(runtime), r.ID,
+				detail.PosterPath, detail.BackdropPath, detail.LogoPath())
 
+			if err == nil && tv != nil {
+				err = showstatus.Store(db, r.ID, tv)
+			}
 			if err != nil {
 				log.Printf("    DB update error for %d: %v", r.ID, err)
 			} else {
 				updated++
+				if detail.PosterPath != "" {
+					recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.Found, "")
+				} else {
+					recordStatus(enrichstatus.EntityTitle, r.ID, enrichstatus.NotFound, "")
+				}
 			}
 			processed++
 		}
 	}
 
-	log.Printf("[2.1] TMDB backfill complete: %d processed, %d updated", processed, updated)
+	log.Printf("[2.3] TMDB backfill complete: %d processed, %d updated", processed, updated)
+	log.Printf("[2.3] TMDB client: %s", tmdbAPI.Stats())
+}
+
+// recordStatus stores a backfill attempt's outcome in enrichment_status, so
+// the server doesn't refetch a miss before its retry is due.
+func recordStatus(entity string, id int, status, errMsg string) {
+	if err := enrichstatus.Record(db, entity, []int{id}, status, errMsg); err != nil {
+		log.Printf("Failed to record %s enrichment status for %s %d: %v", status, entity, id, err)
+	}
 }
 
 func ensureCustomGenreSchema() error {
@@ -1899,7 +2226,7 @@ func init() {
 	fmt.Println("MediaCanon IMDb Sync")
 	fmt.Println()
 	fmt.Println("Modes:")
-	fmt.Println("  (default)           IMDb import (download, titles, genres, episodes, ratings)")
+	fmt.Println("  (default)           IMDb import (download, titles, genres, episodes, ratings, akas)")
 	fmt.Println("  -genres-export FILE Export unreviewed titles for genre review")
 	fmt.Println("  -genres-import FILE Import genre assignments from reviewed file")
 	fmt.Println()
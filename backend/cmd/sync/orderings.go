@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"mediacanon.org/backend/internal/orderings"
+)
+
+// syncAbsoluteOrderings renumbers every show's 'absolute' episode ordering
+// (see orderings.go in the server) after an episode import: all non-special
+// episodes in one season 1, in season/episode order. Only rows whose number
+// changed are written. episode_orderings comes from schema.sql.
+func syncAbsoluteOrderings() error {
+	res, err := db.Exec(orderings.AbsoluteSQL, nil)
+	if err != nil {
+		return fmt.Errorf("absolute ordering: %w", err)
+	}
+	written, _ := res.RowsAffected()
+
+	// Episodes moved into specials
+	res, err = db.Exec(orderings.AbsoluteCleanupSQL, nil)
+	if err != nil {
+		return fmt.Errorf("absolute ordering cleanup: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+
+	log.Printf("Absolute orderings done: %d written, %d removed", written, deleted)
+	return nil
+}
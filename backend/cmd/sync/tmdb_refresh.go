@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// TMDB refresh
+//
+// The backfill (tmdbBackfillBatch) re-fetches every title flagged
+// needs_backfill_tmdb. Without these stages nothing flags a title once it has
+// been filled, so its TMDB metadata is frozen. Each run flags:
+//
+//   - titles TMDB lists in /movie/changes and /tv/changes since the last run
+//     (new posters, corrected release dates...). Shows also get their
+//     episodes_checked_at cleared, so their episode data is refetched on the
+//     next view;
+//   - once a day, the top -popularity-top titles by tmdb_popularity, whose
+//     popularity drifts without any change being recorded and drives the
+//     "trending" sort.
+
+const (
+	tmdbChangesKey    = "tmdb_changes_since"        // date of the last changes window fetched
+	tmdbPopularityKey = "tmdb_popularity_refreshed" // date of the last popularity refresh
+)
+
+// flagTMDBChanges flags titles TMDB reports changed since the checkpoint in
+// sync_state, walking 14-day windows up to today. The first run only sets
+// the checkpoint, since the backfill it follows is fresh. If TMDB fails the
+// checkpoint stays at the last complete window.
+func flagTMDBChanges() {
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	start, err := time.Parse("2006-01-02", getSyncState(tmdbChangesKey))
+	if err != nil {
+		log.Println("[2.1] No TMDB changes checkpoint yet, starting from today")
+		setSyncState(tmdbChangesKey, today.Format("2006-01-02"))
+		return
+	}
+
+	flagged := int64(0)
+	for {
+		end := start.Add(tmdb.MaxChangesWindow)
+		if end.After(today) {
+			end = today
+		}
+		for _, kind := range []struct{ media, titleType string }{{"movie", "movie"}, {"tv", "show"}} {
+			ids, err := tmdbAPI.Changes(ctx, kind.media, start, end)
+			if err != nil {
+				log.Printf("[2.1] TMDB %s changes failed (%v), resuming from %s next run", kind.media, err, start.Format("2006-01-02"))
+				return
+			}
+			res, err := db.Exec(`UPDATE titles SET needs_backfill_tmdb = true,
+				episodes_checked_at = CASE WHEN type = 'show' THEN NULL ELSE episodes_checked_at END
+				WHERE type = $1 AND tmdb_id = ANY($2) AND needs_backfill_tmdb = false`,
+				kind.titleType, pq.Array(ids))
+			if err != nil {
+				log.Printf("[2.1] Flagging changed %ss failed: %v", kind.titleType, err)
+				return
+			}
+			n, _ := res.RowsAffected()
+			flagged += n
+			log.Printf("  %s to %s: %d %s changes, %d of our titles", start.Format("2006-01-02"), end.Format("2006-01-02"), len(ids), kind.media, n)
+		}
+		// Windows are whole days, so the next run asks for today again and
+		// picks up changes made after this one
+		setSyncState(tmdbChangesKey, end.Format("2006-01-02"))
+		if !end.Before(today) {
+			break
+		}
+		start = end
+	}
+	log.Printf("[2.1] %d changed titles flagged for TMDB backfill", flagged)
+}
+
+// flagPopularTitles flags the top n titles by tmdb_popularity for backfill,
+// at most once a day.
+func flagPopularTitles(n int) {
+	if n <= 0 {
+		return
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	if getSyncState(tmdbPopularityKey) == today {
+		log.Println("[2.2] Popularity already refreshed today, skipping")
+		return
+	}
+	res, err := db.Exec(`UPDATE titles SET needs_backfill_tmdb = true WHERE id IN (
+		SELECT id FROM titles WHERE tmdb_id IS NOT NULL
+		ORDER BY tmdb_popularity DESC NULLS LAST
+		LIMIT $1)`, n)
+	if err != nil {
+		log.Printf("[2.2] Flagging popular titles failed: %v", err)
+		return
+	}
+	flagged, _ := res.RowsAffected()
+	setSyncState(tmdbPopularityKey, today)
+	log.Printf("[2.2] %d most popular titles flagged for popularity refresh", flagged)
+}
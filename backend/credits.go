@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Credits
+//
+// Cast and crew come from IMDb's title.principals and title.crew, imported
+// by cmd/sync into people and title_credits. Ordering is IMDb's billing order;
+// directors and writers known only from title.crew are numbered after the
+// billed principals.
+
+// topBilledCredits is how many credits Movie and Show payloads carry unless
+// ?include=full_credits is set.
+const topBilledCredits = 10
+
+// Credit is one cast or crew entry for a title
+type Credit struct {
+	PersonID    int      `json:"person_id"`
+	IMDbID      string   `json:"imdb_id"`
+	DisplayName string   `json:"display_name"`
+	Category    string   `json:"category"`
+	Job         *string  `json:"job,omitempty"`
+	Characters  []string `json:"characters,omitempty"`
+	Ordering    int      `json:"ordering"`
+}
+
+// loadCreditsForTitle returns a title's credits in billing order, the first
+// limit of them when limit > 0, plus the total number of credits.
+func loadCreditsForTitle(titleID, limit int) ([]Credit, int) {
+	var total int
+	db.QueryRow(`SELECT COUNT(*) FROM title_credits WHERE title_id = $1`, titleID).Scan(&total)
+	if total == 0 {
+		return nil, 0
+	}
+
+	query := `
+		SELECT p.id, p.imdb_id, p.display_name, c.category, c.job, c.characters, c.ordering
+		FROM title_credits c JOIN people p ON p.id = c.person_id
+		WHERE c.title_id = $1 ORDER BY c.ordering`
+	args := []any{titleID}
+	if limit > 0 {
+		query += ` LIMIT $2`
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, total
+	}
+	defer rows.Close()
+	var credits []Credit
+	for rows.Next() {
+		var c Credit
+		rows.Scan(&c.PersonID, &c.IMDbID, &c.DisplayName, &c.Category, &c.Job, pq.Array(&c.Characters), &c.Ordering)
+		credits = append(credits, c)
+	}
+	return credits, total
+}
+
+// includes reports whether the comma-separated ?include= param lists name.
+func includes(r *http.Request, name string) bool {
+	return slices.Contains(strings.Split(r.URL.Query().Get("include"), ","), name)
+}
+
+// creditLimit is the loadCreditsForTitle limit for a request.
+func creditLimit(r *http.Request) int {
+	if includes(r, "full_credits") {
+		return 0
+	}
+	return topBilledCredits
+}
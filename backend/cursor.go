@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyset pagination
+//
+// Title listings are ordered by a list of never-NULL sort keys followed by
+// t.id, all in the same direction, so "the rows after this one" is a single
+// row comparison: (k1, k2, t.id) < ($1, $2, $3). A cursor is the sort mode
+// plus the last row's key values, base64-encoded. Unlike OFFSET this costs the
+// same on every page, and rows don't shift between pages when cmd/sync
+// updates num_votes mid-walk (a title can only move past the cursor, not
+// make the rest of the page repeat or skip).
+
+type keysetSort struct {
+	keys  []string // sort expressions, most significant first; t.id is appended
+	casts []string // SQL type of each key, used to type cursor values
+	desc  bool
+}
+
+// Sort key expressions. NULLs are folded to a value below every real one,
+// matching the NULLS LAST ordering these listings have always used.
+const (
+	keyVotes       = "COALESCE(t.num_votes, -1)"
+	keyRating      = "COALESCE(t.average_rating, -1)"
+	keyPopularity  = "COALESCE(t.tmdb_popularity, -1)"
+	keyStartYear   = "COALESCE(t.start_year, -1)"
+	keyReleaseDate = "COALESCE(t.release_date, '0001-01-01'::date)"
+	keyViews       = "(SELECT COUNT(*) FROM title_views tv WHERE tv.title_id = t.id)"
+)
+
+// titleKeyset returns the ordering for a sort mode. It covers the modes of
+// both /api/titles and discover; relevance is the expression from
+// titleRelevanceExpr, or "" when there is no free-text query.
+func titleKeyset(sortBy, relevance string) keysetSort {
+	switch sortBy {
+	case "relevance":
+		if relevance != "" {
+			return keysetSort{[]string{relevance, keyVotes}, []string{"float8", "integer"}, true}
+		}
+	case "top_rated", "hidden_gems":
+		return keysetSort{[]string{keyRating, keyVotes}, []string{"real", "integer"}, true}
+	case "newest":
+		return keysetSort{[]string{keyStartYear, keyReleaseDate, keyVotes}, []string{"integer", "date", "integer"}, true}
+	case "trending":
+		return keysetSort{[]string{keyPopularity}, []string{"real"}, true}
+	case "popular":
+		return keysetSort{[]string{keyViews}, []string{"bigint"}, true}
+	case "a-z":
+		return keysetSort{[]string{"t.display_name"}, []string{"text"}, false}
+	}
+	return keysetSort{[]string{keyVotes}, []string{"integer"}, true}
+}
+
+func (k keysetSort) orderBy() string {
+	dir := " ASC"
+	if k.desc {
+		dir = " DESC"
+	}
+	return strings.Join(append(append([]string{}, k.keys...), "t.id"), dir+", ") + dir
+}
+
+// selectKeys is appended to a SELECT list so the last row's keys can be read
+// back for the next cursor.
+func (k keysetSort) selectKeys() string {
+	return ", " + strings.Join(k.keys, ", ")
+}
+
+// after restricts w to rows that come after the cursor position.
+func (k keysetSort) after(w *sqlWhere, c *pageCursor) {
+	op := " > "
+	if k.desc {
+		op = " < "
+	}
+	params := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		cast := "integer"
+		if i < len(k.casts) {
+			cast = k.casts[i]
+		}
+		params[i] = w.arg(v) + "::" + cast
+	}
+	w.and("(" + strings.Join(append(append([]string{}, k.keys...), "t.id"), ", ") + ")" + op + "(" + strings.Join(params, ", ") + ")")
+}
+
+// pageCursor is the decoded form of a next_cursor token.
+type pageCursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"` // sort key values, then title_id
+}
+
+var errBadCursor = errors.New("invalid cursor")
+
+// encodeCursor builds the token for the row with the given key values and id.
+func encodeCursor(sortBy string, keys []any, id int) string {
+	values := make([]any, 0, len(keys)+1)
+	for _, v := range keys {
+		switch x := v.(type) {
+		case time.Time:
+			v = x.Format("2006-01-02")
+		case []byte:
+			v = string(x)
+		}
+		values = append(values, v)
+	}
+	values = append(values, id)
+	b, _ := json.Marshal(pageCursor{Sort: sortBy, Values: values})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a token and checks it was issued for sortBy with the
+// expected number of keys. Numbers stay as json.Number so bigint and float
+// values reach Postgres unrounded.
+func decodeCursor(token, sortBy string, k keysetSort) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var c pageCursor
+	if dec.Decode(&c) != nil {
+		return nil, errBadCursor
+	}
+	if c.Sort != sortBy {
+		return nil, errors.New("cursor was issued for sort=" + c.Sort)
+	}
+	if len(c.Values) != len(k.keys)+1 {
+		return nil, errBadCursor
+	}
+	for _, v := range c.Values {
+		switch v.(type) {
+		case json.Number, string:
+		default:
+			return nil, errBadCursor
+		}
+	}
+	return &c, nil
+}
+
+// keyDests returns n scan destinations for the values from selectKeys.
+func keyDests(n int) (ptrs []any, vals []any) {
+	vals = make([]any, n)
+	ptrs = make([]any, n)
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	return ptrs, vals
+}
+
+// countParam reports whether ?count= asks for totals (the default).
+func countParam(s string) bool {
+	b, err := strconv.ParseBool(s)
+	return err != nil || b
+}
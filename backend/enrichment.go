@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Enrichment queue
+//
+// TMDB enrichment (a title's poster and metadata, and a show's episode data)
+// used to run inside page handlers, so a slow TMDB made pages slow. Handlers
+// now queue it in enrichment_jobs and respond with what is known, marking the
+// title pending_enrichment. A pool of workers drains the queue, highest
+// priority first, through the shared rate-limited TMDB client. Jobs are rows,
+// so they survive a restart; a job whose worker died is picked up again once
+// its lock expires. Clients wait for a title's jobs with
+// GET /api/titles/:id/enrichment?wait=N.
+
+// Job kinds
+const (
+	jobTitle    = "title"    // poster and metadata: maybeFetchImage, maybeTMDBBackfill, then the poster placeholder
+	jobEpisodes = "episodes" // maybeFetchEpisodes, then still placeholders
+)
+
+// Job priorities
+const (
+	priorityList = 0  // the title appeared in a list or feed
+	priorityView = 10 // someone opened the title
+)
+
+const (
+	enrichJobTimeout = 2 * time.Minute
+	enrichPollEvery  = 5 * time.Second // for jobs queued by other processes
+	enrichMaxWait    = 60 * time.Second
+)
+
+var (
+	enrichWake = make(chan struct{}, 1)
+
+	enrichWaitersMu sync.Mutex
+	enrichWaiters   = map[int][]chan struct{}{} // title id -> long-pollers
+)
+
+// queueEnrichment queues jobs of kind for titleIDs, raising the priority of
+// any already queued. A job that is running stays locked, so no other worker
+// runs it alongside, and is marked requeued: its worker then unlocks it
+// instead of deleting it, and it runs again, as the data being fetched may
+// predate the request.
+func queueEnrichment(kind string, priority int, titleIDs ...int) {
+	if !tmdbAPI.Enabled() || len(titleIDs) == 0 {
+		return
+	}
+	_, err := db.Exec(`
+		INSERT INTO enrichment_jobs (title_id, kind, priority)
+		SELECT unnest($1::int[]), $2::varchar, $3::int
+		ON CONFLICT (title_id, kind) DO UPDATE SET priority = GREATEST(enrichment_jobs.priority, EXCLUDED.priority),
+			requeued = enrichment_jobs.requeued OR enrichment_jobs.locked_at IS NOT NULL
+	`, pq.Array(titleIDs), kind, priority)
+	if err != nil {
+		log.Printf("Failed to queue %s enrichment for %d titles: %v", kind, len(titleIDs), err)
+		return
+	}
+	select {
+	case enrichWake <- struct{}{}:
+	default:
+	}
+}
+
+// titleNeedsEnrichment reports whether the title job would do anything.
+func titleNeedsEnrichment(t *Title) bool {
+	return titleImageDue(t) || t.NeedsBackfillTMDB || placeholderDue(t)
+}
+
+// episodesDue reports whether a show's episode data is past its 24h cooldown.
+func episodesDue(t *Title) bool {
+	return t.IMDbID != nil && *t.IMDbID != "" &&
+		(t.EpisodesCheckedAt == nil || time.Since(*t.EpisodesCheckedAt) >= 24*time.Hour)
+}
+
+// enrichTitle queues the title job if the title is missing anything and
+// marks it pending.
+func enrichTitle(t *Title, priority int) {
+	if !tmdbAPI.Enabled() || !titleNeedsEnrichment(t) {
+		return
+	}
+	queueEnrichment(jobTitle, priority, t.TitleID)
+	t.PendingEnrichment = true
+}
+
+// enrichShow queues the title job and, past the cooldown, the episodes job.
+func enrichShow(show *Show, priority int) {
+	enrichTitle(&show.Title, priority)
+	if tmdbAPI.Enabled() && episodesDue(&show.Title) {
+		queueEnrichment(jobEpisodes, priority, show.Title.TitleID)
+		show.Title.PendingEnrichment = true
+	}
+}
+
+// startEnrichmentWorkers runs n workers draining enrichment_jobs.
+func startEnrichmentWorkers(n int) {
+	if !tmdbAPI.Enabled() {
+		return
+	}
+	for range n {
+		go enrichmentWorker()
+	}
+	log.Printf("Started %d enrichment workers", n)
+}
+
+func enrichmentWorkerCount() int {
+	if n, err := strconv.Atoi(os.Getenv("ENRICH_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+func enrichmentWorker() {
+	ticker := time.NewTicker(enrichPollEvery)
+	defer ticker.Stop()
+	for {
+		titleID, kind, lockedAt, ok := claimEnrichmentJob()
+		if !ok {
+			select {
+			case <-enrichWake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		runEnrichmentJob(titleID, kind)
+		finishEnrichmentJob(titleID, kind, lockedAt)
+		notifyEnriched(titleID)
+		// Pass the wake-up on: more jobs may be waiting
+		select {
+		case enrichWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// claimEnrichmentJob locks the next job. A job locked over 10 minutes ago
+// belonged to a worker that died, and is claimed again. lockedAt identifies
+// this claim.
+func claimEnrichmentJob() (titleID int, kind string, lockedAt string, ok bool) {
+	err := db.QueryRow(`
+		UPDATE enrichment_jobs SET locked_at = NOW(), requeued = false
+		WHERE (title_id, kind) = (
+			SELECT title_id, kind FROM enrichment_jobs
+			WHERE locked_at IS NULL OR locked_at < NOW() - INTERVAL '10 minutes'
+			ORDER BY priority DESC, created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING title_id, kind, locked_at::text
+	`).Scan(&titleID, &kind, &lockedAt)
+	return titleID, kind, lockedAt, err == nil
+}
+
+// finishEnrichmentJob deletes a job its worker is done with, or unlocks it
+// if it was queued again while running. A job whose lock is no longer
+// lockedAt was claimed again after this claim expired, and is left to that
+// worker.
+func finishEnrichmentJob(titleID int, kind, lockedAt string) {
+	_, err := db.Exec(`DELETE FROM enrichment_jobs WHERE title_id = $1 AND kind = $2 AND locked_at = $3 AND NOT requeued`,
+		titleID, kind, lockedAt)
+	if err == nil {
+		_, err = db.Exec(`UPDATE enrichment_jobs SET locked_at = NULL, requeued = false
+			WHERE title_id = $1 AND kind = $2 AND locked_at = $3 AND requeued`, titleID, kind, lockedAt)
+	}
+	if err != nil {
+		log.Printf("Failed to finish %s enrichment job for title %d: %v", kind, titleID, err)
+	}
+}
+
+func runEnrichmentJob(titleID int, kind string) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Enrichment %s job for title %d panicked: %v", kind, titleID, p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), enrichJobTimeout)
+	defer cancel()
+
+	switch kind {
+	case jobTitle:
+		t, err := getTitleByID(titleID)
+		if err != nil {
+			return
+		}
+		maybeFetchImage(ctx, &t)
+		maybeTMDBBackfill(ctx, &t)
+		fillPlaceholders(ctx, entityPoster, []int{titleID})
+	case jobEpisodes:
+		var showID int
+		if err := db.QueryRow(`SELECT id FROM shows WHERE title_id = $1`, titleID).Scan(&showID); err != nil {
+			return
+		}
+		show, err := getShowByID(showID, true)
+		if err != nil {
+			return
+		}
+		maybeFetchEpisodes(ctx, &show)
+		fillPlaceholders(ctx, entityStill, showEpisodeIDs(&show))
+	default:
+		log.Printf("Unknown enrichment job kind %q for title %d", kind, titleID)
+	}
+}
+
+func notifyEnriched(titleID int) {
+	enrichWaitersMu.Lock()
+	defer enrichWaitersMu.Unlock()
+	for _, ch := range enrichWaiters[titleID] {
+		close(ch)
+	}
+	delete(enrichWaiters, titleID)
+}
+
+func enrichmentPending(titleID int) bool {
+	var pending bool
+	db.QueryRow(`SELECT EXISTS (SELECT 1 FROM enrichment_jobs WHERE title_id = $1)`, titleID).Scan(&pending)
+	return pending
+}
+
+// waitEnrichment blocks until titleID has no queued jobs, ctx is done or
+// timeout passes, and reports whether jobs are still pending.
+func waitEnrichment(ctx context.Context, titleID int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		ch := make(chan struct{})
+		enrichWaitersMu.Lock()
+		enrichWaiters[titleID] = append(enrichWaiters[titleID], ch)
+		enrichWaitersMu.Unlock()
+
+		// Checked after subscribing, so a job finishing in between isn't missed
+		if !enrichmentPending(titleID) {
+			unsubscribeEnriched(titleID, ch)
+			return false
+		}
+		select {
+		case <-ch:
+			// One job done; another kind may still be queued
+		case <-ctx.Done():
+			unsubscribeEnriched(titleID, ch)
+			return true
+		case <-deadline:
+			unsubscribeEnriched(titleID, ch)
+			return enrichmentPending(titleID)
+		}
+	}
+}
+
+func unsubscribeEnriched(titleID int, ch chan struct{}) {
+	enrichWaitersMu.Lock()
+	defer enrichWaitersMu.Unlock()
+	waiters := slices.DeleteFunc(enrichWaiters[titleID], func(c chan struct{}) bool { return c == ch })
+	if len(waiters) == 0 {
+		delete(enrichWaiters, titleID)
+	} else {
+		enrichWaiters[titleID] = waiters
+	}
+}
+
+// handleTitleEnrichment serves /api/titles/:id/enrichment: whether TMDB
+// enrichment is queued for the title. With ?wait=N (seconds, at most 60) it
+// long-polls until the queue is empty for the title or N seconds pass.
+func handleTitleEnrichment(w http.ResponseWriter, r *http.Request, titleID int) {
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+	pending := enrichmentPending(titleID)
+	if wait, err := strconv.Atoi(r.URL.Query().Get("wait")); err == nil && wait > 0 && pending {
+		pending = waitEnrichment(r.Context(), titleID, min(time.Duration(wait)*time.Second, enrichMaxWait))
+	}
+	jsonResponse(w, map[string]any{"title_id": titleID, "pending_enrichment": pending})
+}
+
+func enrichmentQueueLength() int {
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM enrichment_jobs`).Scan(&n)
+	return n
+}
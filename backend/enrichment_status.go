@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+
+	"mediacanon.org/backend/internal/enrichstatus"
+)
+
+// Enrichment status
+//
+// enrichment_status records, per entity and source, how our last attempt to
+// fetch its data went, and when to try again. image_url only ever holds a
+// real URL; "TMDB has no poster for this" is a not_found status, not a magic
+// string in image_url. The SQL is in internal/enrichstatus, which cmd/sync
+// and cmd/sync-images record through too.
+//
+//   - found:     we got what we were after (a poster, an episode still).
+//   - not_found: the source answered but has nothing (no such title, or no
+//     image yet). Images are often added later, so it is retried after a
+//     week, then two, doubling up to six months.
+//   - error:     a transient failure (rate limit, timeout, 5xx). Retried
+//     after an hour, doubling up to a week.
+//
+// attempts counts consecutive unsuccessful attempts and resets on found.
+
+// Entity types
+const (
+	entityTitle   = enrichstatus.EntityTitle
+	entityEpisode = enrichstatus.EntityEpisode
+	entityPoster  = "poster" // a title's poster placeholder; see placeholders.go
+	entityStill   = "still"  // an episode's still placeholder
+)
+
+// Statuses
+const (
+	statusFound    = enrichstatus.Found
+	statusNotFound = enrichstatus.NotFound
+	statusError    = enrichstatus.Error
+)
+
+// recordEnrichment stores an attempt's outcome for entities of one type and
+// schedules the next retry. errMsg is kept for status error.
+func recordEnrichment(entity string, ids []int, status, errMsg string) {
+	if err := enrichstatus.Record(db, entity, ids, status, errMsg); err != nil {
+		log.Printf("Failed to record %s enrichment status for %d %ss: %v", status, len(ids), entity, err)
+	}
+}
+
+// enrichmentBlocked returns which of ids have a retry scheduled in the
+// future, i.e. should not be fetched again yet. Entities never attempted, or
+// whose retry is due, are not in the result.
+func enrichmentBlocked(entity string, ids []int) map[int]bool {
+	blocked, _ := enrichstatus.Blocked(db, entity, ids)
+	return blocked
+}
+
+// titleImageDue reports whether a title has no poster and TMDB may be asked
+// for one now.
+func titleImageDue(t *Title) bool {
+	return needsFetch(t.ImageURL, t.IMDbID) && !enrichmentBlocked(entityTitle, []int{t.TitleID})[t.TitleID]
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Episode search
+//
+// /api/episodes?q= (and the Episodes tab on /titles) matches episode names
+// and synopses across all shows. Synopses are prose, so unlike title search
+// this uses the 'english' text search config (stemming, stop words) and must
+// match idx_show_episodes_search in schema.sql.
+
+const episodeSearchVector = `to_tsvector('english', COALESCE(e.display_name, '') || ' ' || COALESCE(e.synopsis, ''))`
+
+// EpisodeShow identifies the show an episode search hit belongs to
+type EpisodeShow struct {
+	ShowID        int     `json:"show_id"`
+	TitleID       int     `json:"title_id"`
+	DisplayName   string  `json:"display_name"`
+	StartYear     *int    `json:"start_year,omitempty"`
+	ImageURL      *string `json:"image_url,omitempty"`
+	ImageBlurhash *string `json:"image_blurhash,omitempty"`
+	ImageColor    *string `json:"image_color,omitempty"`
+	NumVotes      *int    `json:"num_votes,omitempty"`
+}
+
+// EpisodeSearchResult is an episode matched by /api/episodes?q=
+type EpisodeSearchResult struct {
+	EpisodeID     int         `json:"episode_id"`
+	SeasonID      int         `json:"season_id"`
+	SeasonNumber  int         `json:"season_number"`
+	EpisodeNumber int         `json:"episode_number"`
+	DisplayName   *string     `json:"display_name,omitempty"`
+	Synopsis      *string     `json:"synopsis,omitempty"`
+	AirDate       *string     `json:"air_date,omitempty"`
+	ImageURL      *string     `json:"image_url,omitempty"`
+	ImageBlurhash *string     `json:"image_blurhash,omitempty"`
+	ImageColor    *string     `json:"image_color,omitempty"`
+	Show          EpisodeShow `json:"show"`
+}
+
+// searchEpisodes finds episodes whose name or synopsis matches q, restricted
+// to shows matching f (its Text and Type are ignored). Hits are scored like
+// titleRelevanceExpr: an exact name match beats a name prefix, then a name
+// substring, then a synopsis-only match, with ln(show num_votes) added so
+// popular shows come first within a tier.
+func searchEpisodes(q string, f titleFilter, limit, offset int) ([]EpisodeSearchResult, int, error) {
+	w := newSQLWhere("")
+	raw := w.arg(strings.TrimSpace(q))
+	pat := w.arg(likeEscape(strings.TrimSpace(q)))
+	w.and(`(` + episodeSearchVector + ` @@ plainto_tsquery('english', ` + raw + `) OR e.display_name ILIKE '%' || ` + pat + ` || '%')`)
+
+	f.Text, f.Type = "", ""
+	f.apply(w)
+
+	from := `
+		FROM show_episodes e
+		JOIN show_seasons ss ON ss.id = e.season_id
+		JOIN shows s ON s.id = ss.show_id
+		JOIN titles t ON t.id = s.title_id`
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*)`+from+w.String(), w.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	score := `((CASE
+		WHEN lower(e.display_name) = lower(` + raw + `) THEN 40
+		WHEN e.display_name ILIKE ` + pat + ` || '%' THEN 30
+		WHEN e.display_name ILIKE '%' || ` + pat + ` || '%' THEN 20
+		ELSE 10 END) + LN(COALESCE(t.num_votes, 0) + 1))`
+
+	rows, err := db.Query(`
+		SELECT e.id, e.season_id, ss.season, e.episode, e.display_name, e.synopsis,
+		       TO_CHAR(e.air_date, 'YYYY-MM-DD'), e.image_url, e.image_blurhash, e.image_color,
+		       s.id, t.id, t.display_name, t.start_year, t.image_url, t.image_blurhash, t.image_color, t.num_votes`+from+w.String()+`
+		ORDER BY `+score+` DESC, t.num_votes DESC NULLS LAST, ss.season, e.episode
+		LIMIT `+strconv.Itoa(limit)+` OFFSET `+strconv.Itoa(offset), w.args...)
+	if err != nil {
+		return nil, total, err
+	}
+	defer rows.Close()
+
+	var results []EpisodeSearchResult
+	for rows.Next() {
+		var e EpisodeSearchResult
+		rows.Scan(&e.EpisodeID, &e.SeasonID, &e.SeasonNumber, &e.EpisodeNumber, &e.DisplayName, &e.Synopsis,
+			&e.AirDate, &e.ImageURL, &e.ImageBlurhash, &e.ImageColor,
+			&e.Show.ShowID, &e.Show.TitleID, &e.Show.DisplayName, &e.Show.StartYear, &e.Show.ImageURL, &e.Show.ImageBlurhash, &e.Show.ImageColor, &e.Show.NumVotes)
+		if !hasImage(e.ImageURL) {
+			e.ImageURL = nil
+		}
+		if !hasImage(e.Show.ImageURL) {
+			e.Show.ImageURL = nil
+		}
+		e.ImageURL = proxyImage("still", e.EpisodeID, e.ImageURL)
+		e.Show.ImageURL = proxyImage("poster", e.Show.TitleID, e.Show.ImageURL)
+		results = append(results, e)
+	}
+	return results, total, nil
+}
+
+func handleAPIEpisodes(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	// Filters in q (lang:, genre:, year: ...) apply to the show
+	f, qerr := parseTitleQuery(strings.TrimSpace(r.URL.Query().Get("q")))
+	if qerr != nil {
+		queryErrorResponse(w, qerr)
+		return
+	}
+	if f.Text == "" {
+		jsonError(w, "q is required", 400)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	episodes, total, err := searchEpisodes(f.Text, f, perPage, (page-1)*perPage)
+	if err != nil {
+		log.Printf("searchEpisodes error: %v", err)
+		jsonError(w, "Database error", 500)
+		return
+	}
+	jsonResponse(w, map[string]any{
+		"episodes":    episodes,
+		"total":       total,
+		"page":        page,
+		"per_page":    perPage,
+		"total_pages": (total + perPage - 1) / perPage,
+	})
+}
+
+// handleEpisodeSearchPage renders the Episodes tab of /titles search results.
+func handleEpisodeSearchPage(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	langFilter := r.URL.Query().Get("lang")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := 50
+
+	f, qerr := parseTitleQuery(q)
+	queryErr := ""
+	if qerr != nil {
+		queryErr = qerr.Error()
+	}
+	if langFilter != "" {
+		f.Langs = []string{langFilter}
+	}
+
+	var episodes []EpisodeSearchResult
+	var total int
+	if qerr == nil && f.Text != "" {
+		var err error
+		episodes, total, err = searchEpisodes(f.Text, f, perPage, (page-1)*perPage)
+		if err != nil {
+			http.Error(w, "Database error", 500)
+			log.Println(err)
+			return
+		}
+	}
+
+	tmpls["titles"].ExecuteTemplate(w, "base", map[string]any{
+		"Tab":        "episodes",
+		"Episodes":   episodes,
+		"Query":      q,
+		"QueryError": queryErr,
+		"Lang":       langFilter,
+		"Page":       page,
+		"TotalPages": (total + perPage - 1) / perPage,
+		"Total":      total,
+	})
+}
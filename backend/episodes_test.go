@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"mediacanon.org/backend/internal/dbtest"
+	"mediacanon.org/backend/internal/tmdb/tmdbtest"
+)
+
+// useTestDB points db at the test database for the length of t.
+func useTestDB(t *testing.T) {
+	saved := db
+	db = dbtest.Open(t)
+	t.Cleanup(func() { db = saved })
+}
+
+// useFakeTMDB points tmdbAPI at fake for the length of t.
+func useFakeTMDB(t *testing.T, fake *tmdbtest.Fake) {
+	srv := fake.Start()
+	saved := tmdbAPI
+	tmdbAPI = tmdbtest.NewClient(srv)
+	t.Cleanup(func() {
+		tmdbAPI = saved
+		srv.Close()
+	})
+}
+
+// insertShow creates a show with TMDB id tmdbID and episodes[i] episodes in
+// season i+1, and removes it when t is done. It returns the show's id.
+func insertShow(t *testing.T, imdbID string, tmdbID int, episodes ...int) int {
+	t.Helper()
+	var titleID, showID int
+	err := db.QueryRow(`INSERT INTO titles (type, display_name, imdb_id, tmdb_id, needs_backfill_tmdb)
+		VALUES ('show', 'Test show', $1, $2, false) RETURNING id`, imdbID, tmdbID).Scan(&titleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM enrichment_status WHERE entity_type = 'episode' AND entity_id IN
+			(SELECT e.id FROM show_episodes e JOIN show_seasons ss ON ss.id = e.season_id WHERE ss.show_id = $1)`, showID)
+		db.Exec(`DELETE FROM titles WHERE id = $1`, titleID)
+	})
+	if err := db.QueryRow(`INSERT INTO shows (title_id) VALUES ($1) RETURNING id`, titleID).Scan(&showID); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range episodes {
+		var seasonID int
+		if err := db.QueryRow(`INSERT INTO show_seasons (show_id, season) VALUES ($1, $2) RETURNING id`, showID, i+1).Scan(&seasonID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`INSERT INTO show_episodes (season_id, episode) SELECT $1, generate_series(1, $2)`, seasonID, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return showID
+}
+
+// TMDB lists some shows as one flat season. Our season 2 episodes are then
+// found by their absolute number in TMDB's season 1, and that match is
+// stored so the next fetch doesn't ask for season 2 again.
+func TestMaybeFetchEpisodesFlatSeasons(t *testing.T) {
+	useTestDB(t)
+	fake := tmdbtest.New()
+	fake.FlatSeasons(1396) // fixtures: 7 episodes in season 1, 13 in season 2
+	useFakeTMDB(t, fake)
+	showID := insertShow(t, "tt99903747", 1396, 7, 13)
+
+	show, err := getShowByID(showID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maybeFetchEpisodes(context.Background(), &show)
+
+	s2e1 := show.Seasons[1].Episodes[0]
+	if s2e1.StillPath == nil || *s2e1.StillPath != "/fixture-1396-s02e01.jpg" {
+		t.Errorf("S2E1 still = %v, want TMDB S1E8's /fixture-1396-s02e01.jpg", s2e1.StillPath)
+	}
+	var stored *string
+	db.QueryRow(`SELECT still_path FROM show_episodes WHERE id = $1`, s2e1.EpisodeID).Scan(&stored)
+	if stored == nil || *stored != "/fixture-1396-s02e01.jpg" {
+		t.Errorf("stored S2E1 still = %v, want /fixture-1396-s02e01.jpg", stored)
+	}
+	aired := loadEpisodeOrdering(showID, "aired")
+	for _, sn := range show.Seasons {
+		for _, ep := range sn.Episodes {
+			want := orderingPos{1, ep.EpisodeNumber}
+			if sn.SeasonNumber == 2 {
+				want.Episode += 7
+			}
+			if aired[ep.EpisodeID] != want {
+				t.Errorf("S%dE%d aired at %v, want %v", sn.SeasonNumber, ep.EpisodeNumber, aired[ep.EpisodeID], want)
+			}
+		}
+	}
+	if n := fake.Count("/tv/1396/season/1"); n != 1 {
+		t.Errorf("season 1 fetched %d times, want 1", n)
+	}
+	if n := fake.Count("/tv/1396/season/2"); n != 1 {
+		t.Errorf("season 2 fetched %d times, want 1", n)
+	}
+
+	// Once matched, and with every still found, there is nothing to fetch
+	db.Exec(`UPDATE titles SET episodes_checked_at = NULL WHERE id = $1`, show.Title.TitleID)
+	if show, err = getShowByID(showID, true); err != nil {
+		t.Fatal(err)
+	}
+	maybeFetchEpisodes(context.Background(), &show)
+	if n := fake.Count("/tv/1396/season/"); n != 2 {
+		t.Errorf("%d season fetches after refetching, want still 2", n)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Facets
+//
+// ?facets=type,genre,decade,country,lang,rating on /api/titles and
+// /api/discover returns value counts for the current result set. Facets are
+// disjunctive: each is counted with every other active filter applied but its
+// own filter removed, so after picking Drama the genre facet still shows how
+// many Comedy results there would be.
+
+// FacetValue is one bucket of a facet. Filter is the query-syntax token that
+// selects it (see query.go), so clients can append it to q.
+type FacetValue struct {
+	Value  string `json:"value"`
+	Label  string `json:"label"`
+	Count  int    `json:"count"`
+	Filter string `json:"filter"`
+}
+
+type facetDef struct {
+	label  string
+	keys   []string // query-syntax keys this facet filters on
+	join   string
+	expr   string // bucket expression; NULL rows are skipped
+	order  string
+	limit  int
+	clear  func(*titleFilter)
+	name   func(value string) string // display label for a bucket
+	filter func(value string) string
+}
+
+// facetOrder is the order facets are listed in docs and on /titles.
+var facetOrder = []string{"type", "genre", "decade", "country", "lang", "rating"}
+
+var ratingBands = []struct {
+	value, label, filter string
+}{
+	{"9", "9+", "rating:9.."},
+	{"8", "8–9", "rating:8..8.9"},
+	{"7", "7–8", "rating:7..7.9"},
+	{"6", "6–7", "rating:6..6.9"},
+	{"5", "5–6", "rating:5..5.9"},
+	{"0", "Under 5", "rating:<5"},
+}
+
+var facetDefs = map[string]facetDef{
+	"type": {
+		label: "Type",
+		keys:  []string{"type"},
+		expr:  "t.type",
+		order: "2 DESC",
+		clear: func(f *titleFilter) { f.Type = "" },
+		name: func(v string) string {
+			if v == "show" {
+				return "Shows"
+			}
+			return "Movies"
+		},
+		filter: func(v string) string { return "type:" + v },
+	},
+	"genre": {
+		label: "Genre",
+		keys:  []string{"genre", "genres"},
+		join:  " JOIN title_genres ftg ON ftg.title_id = t.id JOIN genres fg ON fg.id = ftg.genre_id",
+		expr:  "fg.name",
+		order: "2 DESC, 1",
+		limit: 40,
+		clear: func(f *titleFilter) { f.Genres = nil },
+		name:  func(v string) string { return v },
+		filter: func(v string) string {
+			if strings.ContainsAny(v, ` ,:"`) {
+				return `genre:"` + v + `"`
+			}
+			return "genre:" + v
+		},
+	},
+	"decade": {
+		label: "Decade",
+		keys:  []string{"year"},
+		expr:  "(t.start_year / 10 * 10)::text",
+		order: "1 DESC",
+		clear: func(f *titleFilter) { f.Year = nil },
+		name:  func(v string) string { return v + "s" },
+		filter: func(v string) string {
+			return "year:" + v + ".." + v[:len(v)-1] + "9"
+		},
+	},
+	"country": {
+		label:  "Country",
+		keys:   []string{"country"},
+		expr:   "NULLIF(t.origin_country, '')",
+		order:  "2 DESC, 1",
+		limit:  30,
+		clear:  func(f *titleFilter) { f.Countries = nil },
+		name:   countryDisplay,
+		filter: func(v string) string { return "country:" + v },
+	},
+	"lang": {
+		label:  "Language",
+		keys:   []string{"lang", "language"},
+		expr:   "NULLIF(t.original_language, '')",
+		order:  "2 DESC, 1",
+		limit:  30,
+		clear:  func(f *titleFilter) { f.Langs = nil },
+		name:   langDisplay,
+		filter: func(v string) string { return "lang:" + v },
+	},
+	"rating": {
+		label: "Rating",
+		keys:  []string{"rating"},
+		expr: `CASE WHEN t.average_rating IS NULL THEN NULL
+			WHEN t.average_rating >= 9 THEN '9' WHEN t.average_rating >= 8 THEN '8'
+			WHEN t.average_rating >= 7 THEN '7' WHEN t.average_rating >= 6 THEN '6'
+			WHEN t.average_rating >= 5 THEN '5' ELSE '0' END`,
+		order: "1 DESC",
+		clear: func(f *titleFilter) { f.Rating = nil },
+		name: func(v string) string {
+			for _, b := range ratingBands {
+				if b.value == v {
+					return b.label
+				}
+			}
+			return v
+		},
+		filter: func(v string) string {
+			for _, b := range ratingBands {
+				if b.value == v {
+					return b.filter
+				}
+			}
+			return ""
+		},
+	},
+}
+
+func init() {
+	facetDefs["language"] = facetDefs["lang"]
+}
+
+// parseFacets splits a comma-separated ?facets= value, rejecting unknown names.
+func parseFacets(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := facetDefs[name]; !ok {
+			return nil, fmt.Errorf("Unknown facet: %s (available: %s)", name, strings.Join(facetOrder, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// computeFacets counts each named facet over titles matching base and f,
+// with the facet's own filter removed. The queries run concurrently.
+func computeFacets(names []string, f titleFilter, base string) map[string][]FacetValue {
+	facets := make(map[string][]FacetValue, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		def := facetDefs[name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ff := f
+			def.clear(&ff)
+			w := newSQLWhere(base)
+			ff.apply(w)
+			query := `SELECT ` + def.expr + `, COUNT(*) FROM titles t` + def.join + w.String() +
+				` GROUP BY 1 ORDER BY ` + def.order
+			if def.limit > 0 {
+				query += fmt.Sprintf(` LIMIT %d`, def.limit+1) // +1 leaves room for the NULL bucket
+			}
+			rows, err := db.Query(query, w.args...)
+			if err != nil {
+				log.Printf("computeFacets %s error: %v", name, err)
+				return
+			}
+			defer rows.Close()
+			values := []FacetValue{}
+			for rows.Next() {
+				var v sql.NullString
+				var count int
+				rows.Scan(&v, &count)
+				if !v.Valid || (def.limit > 0 && len(values) == def.limit) {
+					continue
+				}
+				values = append(values, FacetValue{Value: v.String, Label: def.name(v.String), Count: count, Filter: def.filter(v.String)})
+			}
+			mu.Lock()
+			facets[name] = values
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return facets
+}
+
+// Facet chips for /titles
+
+type facetChip struct {
+	Label  string
+	Count  int
+	URL    string
+	Active bool
+}
+
+type facetChipGroup struct {
+	Label     string
+	AllURL    string
+	AnyActive bool
+	Chips     []facetChip
+}
+
+// titleFacetChips turns facet counts into chip links for /titles. Each chip
+// adds its filter token to q; the group's "All" chip strips that facet's
+// tokens from q. Tokens already in q mark their chip active.
+func titleFacetChips(q, typeFilter, langFilter string, names []string, facets map[string][]FacetValue) []facetChipGroup {
+	tokens, _ := tokenizeQuery(q)
+	link := func(q string) string {
+		v := url.Values{}
+		if q != "" {
+			v.Set("q", q)
+		}
+		if typeFilter != "" {
+			v.Set("type", typeFilter)
+		}
+		if langFilter != "" {
+			v.Set("lang", langFilter)
+		}
+		return "/titles?" + v.Encode()
+	}
+
+	var groups []facetChipGroup
+	for _, name := range names {
+		values := facets[name]
+		if len(values) == 0 {
+			continue
+		}
+		def := facetDefs[name]
+		var kept []string
+		active := make(map[string]bool)
+		for _, tok := range tokens {
+			if slices.Contains(def.keys, tok.key) {
+				active[strings.ToLower(tok.raw)] = true
+				continue
+			}
+			kept = append(kept, tok.raw)
+		}
+		rest := strings.Join(kept, " ")
+
+		g := facetChipGroup{Label: def.label, AllURL: link(rest), AnyActive: len(active) > 0}
+		for _, v := range values {
+			g.Chips = append(g.Chips, facetChip{
+				Label:  v.Label,
+				Count:  v.Count,
+				URL:    link(strings.TrimSpace(rest + " " + v.Filter)),
+				Active: active[strings.ToLower(v.Filter)],
+			})
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
@@ -5,10 +5,10 @@ go 1.24.5
 require (
 	fyne.io/systray v1.12.0
 	github.com/lib/pq v1.10.9
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mediacanon.org/backend/internal/imagecache"
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// Image proxy
+//
+// /img/{kind}/{id}/{size} serves a stored TMDB image from a local cache, so
+// pages don't hotlink image.tmdb.org:
+//
+//	/img/poster/1234/w342       titles.poster_path of title 1234
+//	/img/still/5678/w300.webp   show_episodes.still_path of episode 5678
+//
+// Only images we have stored are served: {kind}/{id} is looked up in
+// imageRoutes, and anything without a stored path is a 404, so the proxy
+// can't be used to fetch (and cache) arbitrary TMDB files. The original is
+// fetched from IMAGE_ORIGIN once and resized here to the widths TMDB offers
+// for the kind (tmdb.ImageSizes). Files live in IMAGE_CACHE_DIR (see
+// internal/imagecache) and are served with an ETag and a day's max-age, as
+// the image behind a URL changes when TMDB's does. WebP needs cwebp, on the
+// PATH or at CWEBP; without it .webp requests get JPEG or PNG.
+//
+// Setting IMAGE_PROXY_URL (e.g. "/img", or an absolute URL for a CDN in front
+// of it) points templates and API image URLs at the proxy;
+// IMAGE_PROXY_WEBP=1 makes those URLs ask for WebP.
+
+var (
+	imageCache     *imagecache.Cache
+	imageProxyURL  string // "" = link to TMDB directly
+	imageProxyWebP bool
+)
+
+const tmdbImageBase = "https://image.tmdb.org/t/p/"
+
+// imageRoute is where a /img/ kind's images are stored
+type imageRoute struct {
+	sizes string // tmdb.ImageSizes kind
+	query string // TMDB path and image_url by id
+}
+
+var imageRoutes = map[string]imageRoute{
+	"poster":   {"poster", `SELECT poster_path, image_url FROM titles WHERE id = $1`},
+	"backdrop": {"backdrop", `SELECT backdrop_path, NULL FROM titles WHERE id = $1`},
+	"logo":     {"logo", `SELECT logo_path, NULL FROM titles WHERE id = $1`},
+	"season":   {"poster", `SELECT poster_path, image_url FROM show_seasons WHERE id = $1`},
+	"still":    {"still", `SELECT still_path, image_url FROM show_episodes WHERE id = $1`},
+}
+
+// storedImageFile is the TMDB file stored for kind and id: its path, else
+// the file in its image_url if that is a TMDB one. It returns "" if there is
+// none.
+func storedImageFile(route imageRoute, id int) string {
+	var path, url sql.NullString
+	if err := db.QueryRow(route.query, id).Scan(&path, &url); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Image lookup %d: %v", id, err)
+		}
+		return ""
+	}
+	file := strings.TrimPrefix(path.String, "/")
+	if file == "" {
+		if rest, ok := strings.CutPrefix(url.String, tmdbImageBase); ok {
+			_, file, _ = strings.Cut(rest, "/")
+		}
+	}
+	if !imagecache.ValidFile(file) {
+		return ""
+	}
+	return file
+}
+
+func setupImageCache() {
+	imageProxyURL = strings.TrimRight(os.Getenv("IMAGE_PROXY_URL"), "/")
+	imageProxyWebP = os.Getenv("IMAGE_PROXY_WEBP") == "1"
+
+	dir := os.Getenv("IMAGE_CACHE_DIR")
+	if dir == "" {
+		exePath, err := os.Executable()
+		if err != nil {
+			exePath = "."
+		}
+		dir = filepath.Join(filepath.Dir(exePath), "image_cache")
+	}
+	encoder := os.Getenv("CWEBP")
+	if encoder == "" {
+		encoder, _ = exec.LookPath("cwebp")
+	}
+	c, err := imagecache.New(imagecache.Config{Dir: dir, Origin: os.Getenv("IMAGE_ORIGIN"), WebPEncoder: encoder})
+	if err != nil {
+		log.Printf("Image cache disabled: %v", err)
+		return
+	}
+	imageCache = c
+	if imageProxyURL != "" {
+		log.Printf("Serving images through %s (cache in %s, WebP %v)", imageProxyURL, dir, c.WebP())
+	}
+}
+
+// handleImage serves /img/{kind}/{id}/{size}[.webp].
+func handleImage(w http.ResponseWriter, r *http.Request) {
+	// SVGs (logos) can carry script; keep browsers from running it, or from
+	// sniffing any response into something they would
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(405)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/img/"), "/")
+	if imageCache == nil || len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	route, ok := imageRoutes[parts[0]]
+	id, err := strconv.Atoi(parts[1])
+	size, webp := strings.CutSuffix(parts[2], ".webp")
+	if !ok || err != nil || id <= 0 || !slices.Contains(tmdb.ImageSizes[route.sizes], size) {
+		http.NotFound(w, r)
+		return
+	}
+	file := storedImageFile(route, id)
+	if file == "" {
+		http.NotFound(w, r)
+		return
+	}
+	width := 0
+	if size != "original" {
+		width = sizeWidth(size)
+	}
+
+	// Other requests may be waiting on this fetch, so it outlives this client
+	img, err := imageCache.Get(context.WithoutCancel(r.Context()), file, width, webp)
+	if errors.Is(err, imagecache.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Image %s/%d/%s (%s): %v", parts[0], id, parts[2], file, err)
+		http.Error(w, "Image unavailable", 502)
+		return
+	}
+	f, err := os.Open(img.Path)
+	if err != nil {
+		http.Error(w, "Image unavailable", 500)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Image unavailable", 500)
+		return
+	}
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", img.ETag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "", stat.ModTime(), f)
+}
+
+// proxyImageURL points a TMDB image URL, stored as kind for id (see
+// imageRoutes), at the proxy if IMAGE_PROXY_URL is set, at the largest
+// standard size no wider than the URL's (image_url stills are w400, which
+// TMDB serves but doesn't list). Other URLs are returned as they are.
+func proxyImageURL(kind string, id int, url string) string {
+	if imageProxyURL == "" {
+		return url
+	}
+	rest, ok := strings.CutPrefix(url, tmdbImageBase)
+	if !ok {
+		return url
+	}
+	size, file, ok := strings.Cut(rest, "/")
+	sizes := tmdb.ImageSizes[imageRoutes[kind].sizes]
+	if !ok || len(sizes) == 0 || id <= 0 || !imagecache.ValidFile(file) {
+		return url
+	}
+	std := sizes[0]
+	for _, s := range sizes {
+		if sizeWidth(s) <= sizeWidth(size) {
+			std = s
+		}
+	}
+	if imageProxyWebP {
+		std += ".webp"
+	}
+	return imageProxyURL + "/" + kind + "/" + strconv.Itoa(id) + "/" + std
+}
+
+// proxyImage is proxyImageURL for an optional URL.
+func proxyImage(kind string, id int, url *string) *string {
+	if url == nil || imageProxyURL == "" {
+		return url
+	}
+	u := proxyImageURL(kind, id, *url)
+	return &u
+}
+
+// proxyDiscoverImages returns titles with their posters proxied. The slice
+// is copied, as it may be shared (the carousel cache).
+func proxyDiscoverImages(titles []DiscoverTitle) []DiscoverTitle {
+	if imageProxyURL == "" {
+		return titles
+	}
+	titles = slices.Clone(titles)
+	for i := range titles {
+		titles[i].ImageURL = proxyImage("poster", titles[i].TitleID, titles[i].ImageURL)
+	}
+	return titles
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// Images
+//
+// Titles, seasons and episodes store TMDB file paths (poster_path,
+// backdrop_path, logo_path, still_path) next to image_url, so the API can
+// offer every size instead of the one baked into image_url. Responses carry
+// an images object with a URL per configured size:
+//
+//	"images": {"poster": {"w185": "...", "w500": "...", "original": "..."}, "logo": {...}}
+//
+// ?image_size=w185 narrows each kind to its closest configured size and
+// points image_url at that size too. IMAGE_SIZES configures the sizes, e.g.
+// "poster=w185,w500,original;backdrop=w1280". With IMAGE_PROXY_URL set, the
+// URLs point at our image proxy instead of TMDB (see image_proxy.go).
+
+// ImageSet maps an image kind ("poster", "backdrop", "logo", "still") to its
+// URLs by size.
+type ImageSet map[string]map[string]string
+
+// titleImageColumns selects a title's TMDB paths and poster placeholder from
+// titles t; scan into Title.imageDests().
+const titleImageColumns = `t.poster_path, t.backdrop_path, t.logo_path, t.image_blurhash, t.image_color, t.placeholder_path`
+
+func (t *Title) imageDests() []any {
+	return []any{&t.PosterPath, &t.BackdropPath, &t.LogoPath, &t.ImageBlurhash, &t.ImageColor, &t.PlaceholderPath}
+}
+
+// imageSizes are the sizes offered per kind, smallest first
+var imageSizes = map[string][]string{
+	"poster":   {"w185", "w342", "w500", "original"},
+	"backdrop": {"w300", "w780", "w1280", "original"},
+	"logo":     {"w185", "w300", "w500", "original"},
+	"still":    {"w185", "w300", "original"},
+}
+
+// loadImageSizes applies IMAGE_SIZES. Sizes TMDB doesn't serve for a kind
+// are skipped.
+func loadImageSizes() {
+	for _, entry := range strings.Split(os.Getenv("IMAGE_SIZES"), ";") {
+		kind, list, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		var sizes []string
+		for _, size := range strings.Split(list, ",") {
+			size = strings.TrimSpace(size)
+			if slices.Contains(tmdb.ImageSizes[kind], size) {
+				sizes = append(sizes, size)
+			} else {
+				log.Printf("IMAGE_SIZES: TMDB has no %s size %q, skipping", kind, size)
+			}
+		}
+		if len(sizes) > 0 {
+			slices.SortFunc(sizes, func(a, b string) int { return sizeWidth(a) - sizeWidth(b) })
+			imageSizes[kind] = sizes
+		}
+	}
+}
+
+// sizeWidth is a size's width in pixels: 185 for "w185", the largest for
+// "original", 0 if size isn't one.
+func sizeWidth(size string) int {
+	if size == "original" {
+		return math.MaxInt32
+	}
+	if n, ok := strings.CutPrefix(size, "w"); ok {
+		if w, err := strconv.Atoi(n); err == nil && w > 0 {
+			return w
+		}
+	}
+	return 0
+}
+
+// imageSizeParam reads ?image_size=, writing a 400 if it isn't a size.
+func imageSizeParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	size := r.URL.Query().Get("image_size")
+	if size != "" && sizeWidth(size) == 0 {
+		jsonError(w, "Invalid image_size: "+size+` (e.g. "w342" or "original")`, 400)
+		return "", false
+	}
+	return size, true
+}
+
+// closestSize is the smallest configured size of kind at least as wide as
+// size, else the largest.
+func closestSize(kind, size string) string {
+	sizes := imageSizes[kind]
+	want := sizeWidth(size)
+	for _, s := range sizes {
+		if sizeWidth(s) >= want {
+			return s
+		}
+	}
+	return sizes[len(sizes)-1]
+}
+
+// add puts kind's URLs for path into the set: every configured size, or only
+// the closest to size if one was asked for. proxy points a TMDB URL of kind
+// at the image proxy. It returns the URL at size, or "" if path is empty or
+// no size was asked for.
+func (set ImageSet) add(kind string, path *string, size string, proxy func(kind, url string) string) string {
+	if path == nil || *path == "" {
+		return ""
+	}
+	urls := map[string]string{}
+	if size != "" {
+		s := closestSize(kind, size)
+		urls[s] = proxy(kind, tmdb.ImageURL(s, *path))
+		set[kind] = urls
+		return urls[s]
+	}
+	for _, s := range imageSizes[kind] {
+		urls[s] = proxy(kind, tmdb.ImageURL(s, *path))
+	}
+	set[kind] = urls
+	return ""
+}
+
+// images builds the set for paths (kind -> path) and, when size is given,
+// repoints imageURL at the primary kind's URL of that size. Otherwise
+// imageURL is only pointed at the image proxy, if there is one.
+func images(size string, imageURL **string, primary string, proxy func(kind, url string) string, paths map[string]*string) ImageSet {
+	set := ImageSet{}
+	if *imageURL != nil {
+		url := proxy(primary, **imageURL)
+		*imageURL = &url
+	}
+	for kind, path := range paths {
+		if url := set.add(kind, path, size, proxy); url != "" && kind == primary {
+			*imageURL = &url
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
+
+// titleImageProxy proxies a title's images, which imageRoutes knows by kind.
+func titleImageProxy(titleID int) func(kind, url string) string {
+	return func(kind, url string) string { return proxyImageURL(kind, titleID, url) }
+}
+
+// ownImageProxy proxies images stored on a season or episode, as route.
+func ownImageProxy(route string, id int) func(kind, url string) string {
+	return func(_, url string) string { return proxyImageURL(route, id, url) }
+}
+
+func (t *Title) applyImages(size string) {
+	t.Images = images(size, &t.ImageURL, "poster", titleImageProxy(t.TitleID), map[string]*string{
+		"poster": t.PosterPath, "backdrop": t.BackdropPath, "logo": t.LogoPath,
+	})
+}
+
+func (t *TitleSearchResult) applyImages(size string) {
+	t.Images = images(size, &t.ImageURL, "poster", titleImageProxy(t.TitleID), map[string]*string{"poster": t.PosterPath})
+}
+
+func (e *Episode) applyImages(size string) {
+	e.Images = images(size, &e.ImageURL, "still", ownImageProxy("still", e.EpisodeID), map[string]*string{"still": e.StillPath})
+}
+
+// applyImages sets the season's images and its episodes'.
+func (s *Season) applyImages(size string) {
+	s.Images = images(size, &s.ImageURL, "poster", ownImageProxy("season", s.SeasonID), map[string]*string{"poster": s.PosterPath})
+	for i := range s.Episodes {
+		s.Episodes[i].applyImages(size)
+	}
+}
+
+// applyImages sets the show's images and those of its seasons and episodes.
+func (s *Show) applyImages(size string) {
+	s.Title.applyImages(size)
+	for i := range s.Seasons {
+		s.Seasons[i].applyImages(size)
+	}
+}
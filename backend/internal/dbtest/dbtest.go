@@ -0,0 +1,61 @@
+// Package dbtest opens a Postgres database for tests that need one.
+//
+// Tests run against TEST_DATABASE_URL and are skipped when it is unset, so
+// go test passes without a database:
+//
+//	db := dbtest.Open(t)
+//
+// schema.sql is applied on every Open; it only creates what is missing. Tests
+// share the database with each other (and with whatever else is in it), so
+// they create their own rows and remove them when done.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// schemaLock is the advisory lock key held while applying schema.sql, as
+// packages' tests run in parallel
+const schemaLock = 0x6d63_7363 // "mcsc"
+
+// Open connects to TEST_DATABASE_URL and applies schema.sql, or skips t if
+// TEST_DATABASE_URL is unset. The connection is closed when t finishes.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, file, _, _ := runtime.Caller(0)
+	schema, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, schemaLock); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, schemaLock)
+	if _, err := conn.ExecContext(ctx, string(schema)); err != nil {
+		t.Fatalf("applying schema.sql: %v", err)
+	}
+	return db
+}
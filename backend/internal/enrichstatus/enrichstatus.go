@@ -0,0 +1,94 @@
+// Package enrichstatus reads and writes enrichment_status, the per-entity
+// record of how the last TMDB fetch went and when to try again. The server,
+// cmd/sync and cmd/sync-images all record their attempts through it, so a
+// miss found by one is not refetched by another before its retry is due. The
+// statuses are described in the server's enrichment_status.go.
+package enrichstatus
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Entity types fetched from TMDB; the server adds its own
+const (
+	EntityTitle   = "title"   // a title's poster and metadata
+	EntityEpisode = "episode" // an episode's still and metadata
+)
+
+// Statuses
+const (
+	Found    = "found"
+	NotFound = "not_found"
+	Error    = "error"
+)
+
+// SourceTMDB is the only source so far
+const SourceTMDB = "tmdb"
+
+// retryBackoff is the first retry delay and the cap for each unsuccessful status
+var retryBackoff = map[string][2]time.Duration{
+	NotFound: {7 * 24 * time.Hour, 180 * 24 * time.Hour},
+	Error:    {time.Hour, 7 * 24 * time.Hour},
+}
+
+// Record stores an attempt's outcome for entities of one type and schedules
+// the next retry. errMsg is kept for status Error.
+func Record(db *sql.DB, entity string, ids []int, status, errMsg string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	backoff := retryBackoff[status] // zero for found: no retry
+	_, err := db.Exec(`
+		INSERT INTO enrichment_status AS es (entity_type, entity_id, source, status, attempts, last_attempt_at, next_retry_at, last_error)
+		SELECT $1::varchar, unnest($2::int[]), $3::varchar, $4::varchar,
+			CASE WHEN $4 = 'found' THEN 0 ELSE 1 END, NOW(),
+			CASE WHEN $4 = 'found' THEN NULL ELSE NOW() + $5::float8 * INTERVAL '1 second' END,
+			NULLIF($7, '')
+		ON CONFLICT (entity_type, entity_id, source) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempts = CASE WHEN EXCLUDED.status = 'found' THEN 0 ELSE es.attempts + 1 END,
+			last_attempt_at = NOW(),
+			next_retry_at = CASE WHEN EXCLUDED.status = 'found' THEN NULL
+				ELSE NOW() + LEAST($5::float8 * POWER(2, LEAST(es.attempts, 20)), $6::float8) * INTERVAL '1 second' END,
+			last_error = EXCLUDED.last_error
+	`, entity, pq.Array(ids), SourceTMDB, status, backoff[0].Seconds(), backoff[1].Seconds(), errMsg)
+	return err
+}
+
+// Blocked returns which of ids have a retry scheduled in the future, i.e.
+// should not be fetched again yet. Entities never attempted, or whose retry
+// is due, are not in the result.
+func Blocked(db *sql.DB, entity string, ids []int) (map[int]bool, error) {
+	blocked := map[int]bool{}
+	if len(ids) == 0 {
+		return blocked, nil
+	}
+	rows, err := db.Query(`
+		SELECT entity_id FROM enrichment_status
+		WHERE entity_type = $1 AND source = $2 AND entity_id = ANY($3) AND next_retry_at > NOW()
+	`, entity, SourceTMDB, pq.Array(ids))
+	if err != nil {
+		return blocked, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return blocked, err
+		}
+		blocked[id] = true
+	}
+	return blocked, rows.Err()
+}
+
+// NotBlockedSQL is a WHERE condition, for a query listing entities of type
+// entity by the id column idColumn, that drops the ones Blocked would
+// report. entity and idColumn are SQL, not user input.
+func NotBlockedSQL(entity, idColumn string) string {
+	return `NOT EXISTS (SELECT 1 FROM enrichment_status es
+		WHERE es.entity_type = '` + entity + `' AND es.source = '` + SourceTMDB + `'
+		  AND es.entity_id = ` + idColumn + ` AND es.next_retry_at > NOW())`
+}
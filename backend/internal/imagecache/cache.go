@@ -0,0 +1,271 @@
+// Package imagecache fetches images from an upstream origin once, keeps them
+// on local disk and derives resized (and optionally WebP) variants from them.
+//
+// Files are content-addressed: an upstream file is stored under the SHA-256 of
+// its bytes, with its variants beside it, and a small index maps upstream file
+// names to hashes:
+//
+//	dir/index/<file>               the hash of <file>'s bytes
+//	dir/ab/abcdef.../original      the upstream bytes
+//	dir/ab/abcdef.../w342.jpg      a variant
+//	dir/placeholders/<file>        <file>'s placeholder (see Placeholder)
+//
+// Identical upstream files share one entry, and a variant's ETag follows from
+// the hash alone.
+package imagecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOrigin serves TMDB images at full size
+const DefaultOrigin = "https://image.tmdb.org/t/p/original"
+
+// maxUpstreamBytes bounds an upstream image; TMDB originals stay well below it
+const maxUpstreamBytes = 32 << 20
+
+// ErrNotFound means the origin has no such file.
+var ErrNotFound = errors.New("imagecache: not found")
+
+// fileName is what an upstream file may be called: TMDB file names are
+// base62 with an extension
+var fileName = regexp.MustCompile(`^[A-Za-z0-9_-]+\.(jpg|jpeg|png|svg)$`)
+
+// ValidFile reports whether name can be fetched from the origin.
+func ValidFile(name string) bool {
+	return fileName.MatchString(name)
+}
+
+// Config configures a Cache.
+type Config struct {
+	Dir    string // where files are kept
+	Origin string // upstream base URL; a file is fetched from Origin + "/" + name. Default DefaultOrigin
+	// ThumbOrigin serves small renditions, which placeholders are computed
+	// from. Default Origin with a final "/original" replaced by "/w92"
+	// (TMDB's smallest size), else Origin.
+	ThumbOrigin string
+	// WebPEncoder is the path of cwebp. Without it WebP requests get the
+	// source format instead.
+	WebPEncoder string
+	Client      *http.Client // default: 30s timeout
+}
+
+// Cache is safe for concurrent use; concurrent requests for one upstream file
+// or variant share the work.
+type Cache struct {
+	cfg Config
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// Image is a variant on disk.
+type Image struct {
+	Path        string
+	ContentType string
+	ETag        string // quoted, ready for the ETag header
+}
+
+// New creates a Cache, making cfg.Dir if needed.
+func New(cfg Config) (*Cache, error) {
+	if cfg.Origin == "" {
+		cfg.Origin = DefaultOrigin
+	}
+	cfg.Origin = strings.TrimRight(cfg.Origin, "/")
+	if cfg.ThumbOrigin == "" {
+		cfg.ThumbOrigin = cfg.Origin
+		if base, ok := strings.CutSuffix(cfg.Origin, "/original"); ok {
+			cfg.ThumbOrigin = base + "/w92"
+		}
+	}
+	cfg.ThumbOrigin = strings.TrimRight(cfg.ThumbOrigin, "/")
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	for _, sub := range []string{"index", "placeholders"} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{cfg: cfg, inflight: map[string]*call{}}, nil
+}
+
+// WebP reports whether WebP variants can be made.
+func (c *Cache) WebP() bool {
+	return c.cfg.WebPEncoder != ""
+}
+
+// Get returns name resized to width (0 = as uploaded), as WebP if webp is set
+// and an encoder is configured, fetching and resizing on first use. Images are
+// never enlarged, and SVGs are always served as they are.
+func (c *Cache) Get(ctx context.Context, name string, width int, webp bool) (*Image, error) {
+	if !ValidFile(name) {
+		return nil, ErrNotFound
+	}
+	hash, err := c.original(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	dir := c.objectDir(hash)
+	srcType := contentType(name)
+	if srcType == "image/svg+xml" || (width == 0 && !(webp && c.WebP())) {
+		return &Image{filepath.Join(dir, "original"), srcType, etag(hash, "original")}, nil
+	}
+
+	variant := "original"
+	if width > 0 {
+		variant = fmt.Sprintf("w%d", width)
+	}
+	ext, typ := ".jpg", "image/jpeg"
+	if srcType == "image/png" {
+		ext, typ = ".png", "image/png"
+	}
+	if webp && c.WebP() {
+		ext, typ = ".webp", "image/webp"
+	}
+	path := filepath.Join(dir, variant+ext)
+	err = c.once(path, func() error {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		return c.makeVariant(filepath.Join(dir, "original"), path, width, srcType, ext == ".webp")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Image{path, typ, etag(hash, variant+ext)}, nil
+}
+
+// original makes sure name's upstream bytes are on disk and returns their hash.
+func (c *Cache) original(ctx context.Context, name string) (string, error) {
+	indexPath := filepath.Join(c.cfg.Dir, "index", name)
+	var hash string
+	err := c.once(indexPath, func() error {
+		if b, err := os.ReadFile(indexPath); err == nil {
+			hash = string(b)
+			return nil
+		}
+		body, err := c.fetch(ctx, c.cfg.Origin, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		hash = hex.EncodeToString(sum[:])
+		dir := c.objectDir(hash)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, "original"), body); err != nil {
+			return err
+		}
+		return writeFile(indexPath, []byte(hash))
+	})
+	if hash == "" && err == nil {
+		// Another caller did the work
+		b, rerr := os.ReadFile(indexPath)
+		hash, err = string(b), rerr
+	}
+	return hash, err
+}
+
+// fetch GETs name from origin.
+func (c *Cache) fetch(ctx context.Context, origin, name string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("imagecache: %s from origin for %s", resp.Status, name)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxUpstreamBytes {
+		return nil, fmt.Errorf("imagecache: %s is over %d bytes", name, maxUpstreamBytes)
+	}
+	return body, nil
+}
+
+// once runs fn for key unless a run for key is in progress, in which case it
+// waits for that run and returns its error.
+func (c *Cache) once(key string, fn func() error) error {
+	c.mu.Lock()
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	cl.err = fn()
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(cl.done)
+	return cl.err
+}
+
+func (c *Cache) objectDir(hash string) string {
+	return filepath.Join(c.cfg.Dir, hash[:2], hash)
+}
+
+// writeFile writes via a temporary file, so readers never see a partial file.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func contentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return "image/png"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	return "image/jpeg"
+}
+
+func etag(hash, variant string) string {
+	return `"` + hash[:20] + "-" + variant + `"`
+}
@@ -0,0 +1,106 @@
+package imagecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mediacanon.org/backend/internal/blurhash"
+)
+
+// ErrUnsupported means an image can't be decoded (an SVG, or a corrupt file).
+var ErrUnsupported = errors.New("imagecache: unsupported image")
+
+// Placeholder is what a client shows while an image loads.
+type Placeholder struct {
+	BlurHash string
+	Color    string // dominant colour, "#rrggbb"
+}
+
+// placeholderWidth is the thumbnail both are computed from
+const placeholderWidth = 32
+
+// Placeholder returns name's placeholder, computed once per file from its
+// ThumbOrigin rendition. Neither the thumbnail nor the original is kept: a
+// placeholder shouldn't cost a full-size download or disk space.
+func (c *Cache) Placeholder(ctx context.Context, name string) (*Placeholder, error) {
+	if !ValidFile(name) {
+		return nil, ErrNotFound
+	}
+	if contentType(name) == "image/svg+xml" {
+		return nil, ErrUnsupported
+	}
+	path := filepath.Join(c.cfg.Dir, "placeholders", name)
+	var p *Placeholder
+	err := c.once(path, func() error {
+		if b, err := os.ReadFile(path); err == nil {
+			if bh, color, ok := strings.Cut(string(b), "\n"); ok {
+				p = &Placeholder{bh, color}
+				return nil
+			}
+		}
+		body, err := c.fetch(ctx, c.cfg.ThumbOrigin, name)
+		if err != nil {
+			return err
+		}
+		decodeSlots <- struct{}{}
+		defer func() { <-decodeSlots }()
+		img, err := decode(body)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnsupported, name, err)
+		}
+		if img.Bounds().Dx() > placeholderWidth {
+			img = downscale(img, placeholderWidth)
+		}
+		// 3x4 components for portrait images (posters), 4x3 for landscape
+		x, y := 4, 3
+		if img.Bounds().Dy() > img.Bounds().Dx() {
+			x, y = 3, 4
+		}
+		bh, err := blurhash.Encode(img, x, y)
+		if err != nil {
+			return err
+		}
+		p = &Placeholder{bh, dominantColor(img)}
+		return writeFile(path, []byte(p.BlurHash+"\n"+p.Color))
+	})
+	if p == nil && err == nil {
+		// Another caller computed it
+		return c.Placeholder(ctx, name)
+	}
+	return p, err
+}
+
+// dominantColor is the average colour of the most common of 512 colour
+// buckets (3 bits per channel), ignoring transparent pixels.
+func dominantColor(img image.Image) string {
+	type bucket struct{ r, g, b, n int }
+	var buckets [512]bucket
+	best := 0
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// Un-premultiply
+			r, g, b = r*0xffff/a>>8, g*0xffff/a>>8, b*0xffff/a>>8
+			i := int(r>>5<<6 | g>>5<<3 | b>>5)
+			k := &buckets[i]
+			k.r, k.g, k.b, k.n = k.r+int(r), k.g+int(g), k.b+int(b), k.n+1
+			if k.n > buckets[best].n {
+				best = i
+			}
+		}
+	}
+	k := buckets[best]
+	if k.n == 0 {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", k.r/k.n, k.g/k.n, k.b/k.n)
+}
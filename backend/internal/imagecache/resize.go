@@ -0,0 +1,132 @@
+package imagecache
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+const jpegQuality = 85
+
+// maxPixels bounds a decoded image. A decoded image takes 4 bytes a pixel or
+// more whatever its file size, and TMDB originals stay well below this.
+const maxPixels = 50_000_000
+
+// decodeSlots bounds concurrent decoding and encoding, each of which holds
+// a full-size image in memory. Take a slot before calling decode.
+var decodeSlots = make(chan struct{}, runtime.NumCPU())
+
+// decode decodes data after checking its dimensions against maxPixels, so a
+// small file claiming huge dimensions is rejected before any allocation.
+func decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image size %dx%d out of range", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// makeVariant writes src, scaled down to width (0 = as is), to dst as JPEG or
+// PNG following the source, or as WebP.
+func (c *Cache) makeVariant(src, dst string, width int, srcType string, webp bool) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	decodeSlots <- struct{}{}
+	defer func() { <-decodeSlots }()
+	img, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("imagecache: decoding %s: %w", src, err)
+	}
+	if width > 0 && width < img.Bounds().Dx() {
+		img = downscale(img, width)
+	}
+
+	var buf bytes.Buffer
+	if srcType == "image/png" || webp {
+		// PNG keeps transparency (logos), and is lossless input for cwebp
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return err
+	}
+	if !webp {
+		return writeFile(dst, buf.Bytes())
+	}
+	return c.encodeWebP(buf.Bytes(), dst)
+}
+
+// encodeWebP converts PNG bytes to WebP at dst with cwebp.
+func (c *Cache) encodeWebP(pngData []byte, dst string) error {
+	in, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(in.Name())
+	_, err = in.Write(pngData)
+	if cerr := in.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	out := dst + ".tmp"
+	defer os.Remove(out)
+	cmd := exec.Command(c.cfg.WebPEncoder, "-quiet", "-q", "80", "-alpha_q", "100", in.Name(), "-o", out)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("imagecache: cwebp: %v: %s", err, msg)
+	}
+	return os.Rename(out, dst)
+}
+
+// downscale resizes img to width, keeping its aspect ratio, averaging the
+// source pixels that fall in each destination pixel (a box filter: good for
+// shrinking, which is all we do).
+func downscale(img image.Image, width int) *image.RGBA {
+	sb := img.Bounds()
+	height := max(1, (sb.Dy()*width+sb.Dx()/2)/sb.Dx())
+
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+		draw.Draw(src, src.Bounds(), img, sb.Min, draw.Src)
+	}
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := range width {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,24 @@
+// Package orderings holds the episode_orderings SQL that the server and
+// cmd/sync both run, so the two keep numbering episodes the same way. The
+// orderings themselves are described in the server's orderings.go.
+package orderings
+
+// AbsoluteSQL numbers episodes (specials excluded) in our season/episode
+// order as the 'absolute' ordering, writing only rows whose number changed.
+// $1 restricts it to one show; NULL renumbers every show.
+const AbsoluteSQL = `
+	INSERT INTO episode_orderings (episode_id, ordering, show_id, season, episode)
+	SELECT e.id, 'absolute', ss.show_id, 1,
+	       ROW_NUMBER() OVER (PARTITION BY ss.show_id ORDER BY ss.season, e.episode)
+	FROM show_episodes e JOIN show_seasons ss ON ss.id = e.season_id
+	WHERE ($1::int IS NULL OR ss.show_id = $1) AND ss.season > 0
+	ON CONFLICT (episode_id, ordering) DO UPDATE SET season = EXCLUDED.season, episode = EXCLUDED.episode
+	WHERE (episode_orderings.season, episode_orderings.episode) IS DISTINCT FROM (EXCLUDED.season, EXCLUDED.episode)`
+
+// AbsoluteCleanupSQL drops the absolute positions of episodes that moved
+// into specials. $1 is as for AbsoluteSQL.
+const AbsoluteCleanupSQL = `
+	DELETE FROM episode_orderings o
+	USING show_episodes e JOIN show_seasons ss ON ss.id = e.season_id
+	WHERE o.ordering = 'absolute' AND o.episode_id = e.id AND ss.season <= 0
+	  AND ($1::int IS NULL OR ss.show_id = $1)`
@@ -0,0 +1,35 @@
+// Package showstatus writes a show's TMDB status, which the server's lazy
+// fetch and cmd/sync's TMDB backfill both store. How it is used is described
+// in the server's show_status.go.
+package showstatus
+
+import (
+	"database/sql"
+
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// Store saves tv's status, in_production and next and last episodes on the
+// show of title titleID. Missing values are stored as NULL.
+func Store(db *sql.DB, titleID int, tv *tmdb.TV) error {
+	var next, last tmdb.EpisodeRef
+	if tv.NextEpisodeToAir != nil {
+		next = *tv.NextEpisodeToAir
+	}
+	if tv.LastEpisodeToAir != nil {
+		last = *tv.LastEpisodeToAir
+	}
+	_, err := db.Exec(`
+		UPDATE shows SET
+			tmdb_status = NULLIF($1, ''),
+			in_production = $2,
+			next_air_date = NULLIF($3, '')::date,
+			next_episode_season = NULLIF($4, 0),
+			next_episode_number = NULLIF($5, 0),
+			last_episode_season = NULLIF($6, 0),
+			last_episode_number = NULLIF($7, 0)
+		WHERE title_id = $8
+	`, tv.Status, tv.InProduction, next.AirDate, next.SeasonNumber, next.EpisodeNumber,
+		last.SeasonNumber, last.EpisodeNumber, titleID)
+	return err
+}
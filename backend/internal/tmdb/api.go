@@ -0,0 +1,277 @@
+package tmdb
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ImageURL is the image.tmdb.org URL for a file path at size (e.g. "w500"),
+// or "" if path is empty.
+func ImageURL(size, path string) string {
+	if path == "" {
+		return ""
+	}
+	return "https://image.tmdb.org/t/p/" + size + path
+}
+
+// ImageSizes are the sizes image.tmdb.org serves for each kind of image.
+var ImageSizes = map[string][]string{
+	"poster":   {"w92", "w154", "w185", "w342", "w500", "w780", "original"},
+	"backdrop": {"w300", "w780", "w1280", "original"},
+	"logo":     {"w45", "w92", "w154", "w185", "w300", "w500", "original"},
+	"still":    {"w92", "w185", "w300", "original"},
+}
+
+// FindResult is /find/{imdb_id}
+type FindResult struct {
+	TVResults    []FindTV    `json:"tv_results"`
+	MovieResults []FindMovie `json:"movie_results"`
+}
+
+type FindTV struct {
+	ID               int      `json:"id"`
+	PosterPath       string   `json:"poster_path"`
+	BackdropPath     string   `json:"backdrop_path"`
+	OriginalLanguage string   `json:"original_language"`
+	FirstAirDate     string   `json:"first_air_date"`
+	Popularity       float64  `json:"popularity"`
+	OriginCountry    []string `json:"origin_country"`
+}
+
+type FindMovie struct {
+	ID               int      `json:"id"`
+	PosterPath       string   `json:"poster_path"`
+	BackdropPath     string   `json:"backdrop_path"`
+	OriginalLanguage string   `json:"original_language"`
+	ReleaseDate      string   `json:"release_date"`
+	Popularity       float64  `json:"popularity"`
+	OriginCountry    []string `json:"origin_country"`
+}
+
+// Country is an entry of production_countries
+type Country struct {
+	ISO string `json:"iso_3166_1"`
+}
+
+// Details are the fields /movie/{id} and /tv/{id} share
+type Details struct {
+	ID                  int       `json:"id"`
+	PosterPath          string    `json:"poster_path"`
+	BackdropPath        string    `json:"backdrop_path"`
+	OriginalLanguage    string    `json:"original_language"`
+	Popularity          float64   `json:"popularity"`
+	OriginCountry       []string  `json:"origin_country"`
+	ProductionCountries []Country `json:"production_countries"`
+	Images              Images    `json:"images"` // appended by Movie and TV
+}
+
+// Images is the images block appended to details; only logos are requested
+type Images struct {
+	Logos []Image `json:"logos"`
+}
+
+// Image is one entry of Images, best voted first
+type Image struct {
+	FilePath string `json:"file_path"`
+	Language string `json:"iso_639_1"`
+}
+
+// Country is the first origin country, else the first production country.
+func (d *Details) Country() string {
+	if len(d.OriginCountry) > 0 {
+		return d.OriginCountry[0]
+	}
+	if len(d.ProductionCountries) > 0 {
+		return d.ProductionCountries[0].ISO
+	}
+	return ""
+}
+
+// LogoPath is the best English logo, else the best language-neutral one.
+func (d *Details) LogoPath() string {
+	neutral := ""
+	for _, l := range d.Images.Logos {
+		if l.Language == "en" {
+			return l.FilePath
+		}
+		if l.Language == "" && neutral == "" {
+			neutral = l.FilePath
+		}
+	}
+	return neutral
+}
+
+// Movie is /movie/{id}
+type Movie struct {
+	Details
+	ReleaseDate string  `json:"release_date"`
+	Runtime     float64 `json:"runtime"`
+}
+
+// TV is /tv/{id}
+type TV struct {
+	Details
+	FirstAirDate     string      `json:"first_air_date"`
+	Status           string      `json:"status"` // "Returning Series", "Ended", "Canceled", "In Production"...
+	InProduction     bool        `json:"in_production"`
+	LastEpisodeToAir *EpisodeRef `json:"last_episode_to_air"`
+	NextEpisodeToAir *EpisodeRef `json:"next_episode_to_air"`
+}
+
+// EpisodeRef is TV.LastEpisodeToAir / NextEpisodeToAir
+type EpisodeRef struct {
+	AirDate       string `json:"air_date"`
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+}
+
+// Episode is /tv/{id}/season/{n}/episode/{e}, and each entry of Season.Episodes
+type Episode struct {
+	EpisodeNumber int    `json:"episode_number"`
+	Name          string `json:"name"`
+	Overview      string `json:"overview"`
+	StillPath     string `json:"still_path"`
+	AirDate       string `json:"air_date"`
+	Runtime       int    `json:"runtime"`
+}
+
+// Season is /tv/{id}/season/{n}: the season plus all its episodes
+type Season struct {
+	Name         string    `json:"name"`
+	Overview     string    `json:"overview"`
+	PosterPath   string    `json:"poster_path"`
+	AirDate      string    `json:"air_date"`
+	SeasonNumber int       `json:"season_number"`
+	Episodes     []Episode `json:"episodes"`
+}
+
+// EpisodesByNumber indexes s.Episodes by episode number.
+func (s *Season) EpisodesByNumber() map[int]Episode {
+	m := make(map[int]Episode, len(s.Episodes))
+	for _, e := range s.Episodes {
+		m[e.EpisodeNumber] = e
+	}
+	return m
+}
+
+// Episode group types
+const (
+	GroupOriginalAirDate = 1
+	GroupAbsolute        = 2
+	GroupDVD             = 3
+	GroupDigital         = 4
+	GroupStoryArc        = 5
+	GroupProduction      = 6
+	GroupTV              = 7
+)
+
+// EpisodeGroupSummary is an entry of /tv/{id}/episode_groups
+type EpisodeGroupSummary struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Type         int    `json:"type"`
+	EpisodeCount int    `json:"episode_count"`
+	GroupCount   int    `json:"group_count"`
+}
+
+// EpisodeGroup is /tv/episode_group/{id}
+type EpisodeGroup struct {
+	Groups []struct {
+		Name     string `json:"name"`
+		Order    int    `json:"order"`
+		Episodes []struct {
+			SeasonNumber  int `json:"season_number"`
+			EpisodeNumber int `json:"episode_number"`
+			Order         int `json:"order"` // 0-based position within the group
+		} `json:"episodes"`
+	} `json:"groups"`
+}
+
+// Find looks up an IMDb id.
+func (c *Client) Find(ctx context.Context, imdbID string) (*FindResult, error) {
+	var r FindResult
+	err := c.get(ctx, "/find/"+url.PathEscape(imdbID), url.Values{"external_source": {"imdb_id"}}, &r)
+	return &r, err
+}
+
+// detailsQuery appends the logos, English or without text, to a details request
+var detailsQuery = url.Values{"append_to_response": {"images"}, "include_image_language": {"en,null"}}
+
+// Movie fetches /movie/{id}, with logos.
+func (c *Client) Movie(ctx context.Context, id int) (*Movie, error) {
+	var m Movie
+	err := c.get(ctx, fmt.Sprintf("/movie/%d", id), detailsQuery, &m)
+	return &m, err
+}
+
+// TV fetches /tv/{id}, with logos.
+func (c *Client) TV(ctx context.Context, id int) (*TV, error) {
+	var t TV
+	err := c.get(ctx, fmt.Sprintf("/tv/%d", id), detailsQuery, &t)
+	return &t, err
+}
+
+// Season fetches /tv/{id}/season/{n}. ErrNotFound means TMDB has no such season.
+func (c *Client) Season(ctx context.Context, tvID, season int) (*Season, error) {
+	var s Season
+	err := c.get(ctx, fmt.Sprintf("/tv/%d/season/%d", tvID, season), nil, &s)
+	return &s, err
+}
+
+// Episode fetches /tv/{id}/season/{n}/episode/{e}.
+func (c *Client) Episode(ctx context.Context, tvID, season, episode int) (*Episode, error) {
+	var e Episode
+	err := c.get(ctx, fmt.Sprintf("/tv/%d/season/%d/episode/%d", tvID, season, episode), nil, &e)
+	return &e, err
+}
+
+// EpisodeGroups lists a show's episode groups.
+func (c *Client) EpisodeGroups(ctx context.Context, tvID int) ([]EpisodeGroupSummary, error) {
+	var r struct {
+		Results []EpisodeGroupSummary `json:"results"`
+	}
+	err := c.get(ctx, fmt.Sprintf("/tv/%d/episode_groups", tvID), nil, &r)
+	return r.Results, err
+}
+
+// EpisodeGroup fetches one episode group with its episodes.
+func (c *Client) EpisodeGroup(ctx context.Context, id string) (*EpisodeGroup, error) {
+	var g EpisodeGroup
+	err := c.get(ctx, "/tv/episode_group/"+url.PathEscape(id), nil, &g)
+	return &g, err
+}
+
+// MaxChangesWindow is the longest start..end span /movie/changes and
+// /tv/changes accept.
+const MaxChangesWindow = 14 * 24 * time.Hour
+
+// Changes lists the ids of movies or shows (mediaType "movie" or "tv") whose
+// TMDB data changed between start and end, fetching every page.
+func (c *Client) Changes(ctx context.Context, mediaType string, start, end time.Time) ([]int, error) {
+	var ids []int
+	for page := 1; ; page++ {
+		var r struct {
+			Results []struct {
+				ID int `json:"id"`
+			} `json:"results"`
+			TotalPages int `json:"total_pages"`
+		}
+		q := url.Values{
+			"start_date": {start.Format("2006-01-02")},
+			"end_date":   {end.Format("2006-01-02")},
+			"page":       {strconv.Itoa(page)},
+		}
+		if err := c.get(ctx, "/"+mediaType+"/changes", q, &r); err != nil {
+			return ids, err
+		}
+		for _, res := range r.Results {
+			ids = append(ids, res.ID)
+		}
+		if page >= r.TotalPages {
+			return ids, nil
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package tmdb is the one TMDB API client shared by the server, cmd/sync and
+// cmd/sync-images.
+//
+// A Client rate-limits every request through a token bucket, retries 429s
+// (honouring Retry-After, and pausing all callers meanwhile), 5xx responses
+// and network errors with backoff, and counts what it did in Stats. Create one
+// Client per process so the whole process shares one limiter.
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBaseURL is TMDB's v3 API root
+const DefaultBaseURL = "https://api.themoviedb.org/3"
+
+// ErrNotFound is returned for a 404: TMDB has no such resource.
+var ErrNotFound = errors.New("tmdb: not found")
+
+// StatusError is a non-200, non-404 response that retries didn't resolve.
+type StatusError struct {
+	Code int
+	Path string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("tmdb: %s returned status %d", e.Path, e.Code)
+}
+
+// Config configures a Client. Zero fields take the defaults noted.
+type Config struct {
+	APIKey            string
+	BaseURL           string        // DefaultBaseURL
+	RequestsPerSecond float64       // 40, TMDB's documented ceiling is ~50
+	Burst             int           // 10
+	Timeout           time.Duration // 10s per attempt
+	MaxRetries        int           // 3
+	Backoff           time.Duration // 1s, doubled on each retry
+}
+
+// RateFromEnv returns the rate and burst set by TMDB_RPS and TMDB_BURST, or
+// defaultRPS and 0 (Config's default burst) for unset or invalid values.
+// TMDB's quota (~50 requests/s) is per API key, not per process, so the
+// rates of every process sharing a key must add up to less than it.
+func RateFromEnv(defaultRPS float64) (rps float64, burst int) {
+	rps = defaultRPS
+	if v, err := strconv.ParseFloat(os.Getenv("TMDB_RPS"), 64); err == nil && v > 0 {
+		rps = v
+	}
+	if n, err := strconv.Atoi(os.Getenv("TMDB_BURST")); err == nil && n > 0 {
+		burst = n
+	}
+	return rps, burst
+}
+
+// Client calls the TMDB API. It is safe for concurrent use.
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	limiter *limiter
+
+	requests, retries, rateLimited, notFound, errors atomic.Int64
+	waitNanos                                        atomic.Int64
+}
+
+// New returns a Client for cfg.
+func New(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.RequestsPerSecond <= 0 {
+		cfg.RequestsPerSecond = 40
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	return &Client{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout},
+		limiter: newLimiter(cfg.RequestsPerSecond, cfg.Burst),
+	}
+}
+
+// Enabled reports whether c has an API key; without one every call fails.
+func (c *Client) Enabled() bool {
+	return c != nil && c.cfg.APIKey != ""
+}
+
+// Stats is a snapshot of a Client's counters since it was created.
+type Stats struct {
+	Requests    int64         `json:"requests"`     // HTTP attempts, including retries
+	Retries     int64         `json:"retries"`      // attempts repeated after a 429, 5xx or network error
+	RateLimited int64         `json:"rate_limited"` // 429 responses
+	NotFound    int64         `json:"not_found"`    // 404 responses
+	Errors      int64         `json:"errors"`       // calls that failed other than with ErrNotFound
+	Waited      time.Duration `json:"waited_ns"`    // total time callers spent in the limiter
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d requests, %d retries, %d rate limited, %d not found, %d errors, %v waiting on limiter",
+		s.Requests, s.Retries, s.RateLimited, s.NotFound, s.Errors, s.Waited.Round(time.Millisecond))
+}
+
+// Stats returns c's counters; zero for a nil Client.
+func (c *Client) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{
+		Requests:    c.requests.Load(),
+		Retries:     c.retries.Load(),
+		RateLimited: c.rateLimited.Load(),
+		NotFound:    c.notFound.Load(),
+		Errors:      c.errors.Load(),
+		Waited:      time.Duration(c.waitNanos.Load()),
+	}
+}
+
+// get GETs path (relative to the base URL) with query and decodes a 200
+// response into v.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	err := c.do(ctx, path, query, v)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.errors.Add(1)
+	}
+	return err
+}
+
+func (c *Client) do(ctx context.Context, path string, query url.Values, v any) error {
+	if c.cfg.APIKey == "" {
+		return errors.New("tmdb: no API key configured")
+	}
+	query = maps.Clone(query) // callers may share theirs
+	if query == nil {
+		query = url.Values{}
+	}
+	// Errors name the URL without the key, as they end up in logs and in
+	// enrichment_status
+	safeURL := c.cfg.BaseURL + path
+	if len(query) > 0 {
+		safeURL += "?" + query.Encode()
+	}
+	query.Set("api_key", c.cfg.APIKey)
+	u := c.cfg.BaseURL + path + "?" + query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+		c.waitNanos.Add(int64(time.Since(start)))
+
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return err
+		}
+		c.requests.Add(1)
+		resp, err := c.http.Do(req)
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			ue.URL = safeURL
+		}
+
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		case resp.StatusCode == 200:
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				return fmt.Errorf("tmdb: decoding %s: %w", path, err)
+			}
+			return nil
+		case resp.StatusCode == 404:
+			resp.Body.Close()
+			c.notFound.Add(1)
+			return ErrNotFound
+		case resp.StatusCode == 429:
+			resp.Body.Close()
+			c.rateLimited.Add(1)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = &StatusError{Code: 429, Path: path}
+		case resp.StatusCode >= 500:
+			resp.Body.Close()
+			err = &StatusError{Code: resp.StatusCode, Path: path}
+		default:
+			resp.Body.Close()
+			return &StatusError{Code: resp.StatusCode, Path: path}
+		}
+
+		if attempt >= c.cfg.MaxRetries {
+			return err
+		}
+		wait := c.cfg.Backoff << attempt // 1s, 2s, 4s...
+		if retryAfter > 0 {
+			wait = retryAfter
+			// Everyone sharing the limiter backs off, not just this caller
+			c.limiter.pause(wait)
+		}
+		c.retries.Add(1)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package tmdb_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mediacanon.org/backend/internal/tmdb"
+	"mediacanon.org/backend/internal/tmdb/tmdbtest"
+)
+
+func start(t *testing.T) (*tmdbtest.Fake, *tmdb.Client) {
+	t.Helper()
+	fake := tmdbtest.New()
+	srv := fake.Start()
+	t.Cleanup(srv.Close)
+	return fake, tmdbtest.NewClient(srv)
+}
+
+func TestRateLimitWaitsForRetryAfter(t *testing.T) {
+	fake, c := start(t)
+	fake.RateLimit(1, time.Second)
+
+	begin := time.Now()
+	tv, err := c.TV(context.Background(), 1396)
+	if err != nil {
+		t.Fatalf("TV: %v", err)
+	}
+	if tv.ID != 1396 {
+		t.Errorf("TV id = %d, want 1396", tv.ID)
+	}
+	if waited := time.Since(begin); waited < time.Second {
+		t.Errorf("retried after %v, want at least Retry-After's 1s", waited)
+	}
+	st := c.Stats()
+	if st.Requests != 2 || st.Retries != 1 || st.RateLimited != 1 || st.Errors != 0 {
+		t.Errorf("stats = %+v, want 2 requests, 1 retry, 1 rate limited, 0 errors", st)
+	}
+}
+
+func TestServerErrorsAreRetried(t *testing.T) {
+	fake, c := start(t)
+	fake.Fail(2, 503)
+
+	if _, err := c.TV(context.Background(), 1396); err != nil {
+		t.Fatalf("TV: %v", err)
+	}
+	if n := fake.Count("/tv/1396"); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+	if st := c.Stats(); st.Retries != 2 || st.Errors != 0 {
+		t.Errorf("stats = %+v, want 2 retries, 0 errors", st)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	fake, c := start(t)
+	fake.Fail(10, 500)
+
+	_, err := c.TV(context.Background(), 1396)
+	var se *tmdb.StatusError
+	if !errors.As(err, &se) || se.Code != 500 {
+		t.Fatalf("err = %v, want a 500 StatusError", err)
+	}
+	// The first attempt and 3 retries (Config.MaxRetries' default)
+	if n := fake.Count("/tv/1396"); n != 4 {
+		t.Errorf("%d requests, want 4", n)
+	}
+	if st := c.Stats(); st.Errors != 1 {
+		t.Errorf("stats = %+v, want 1 error", st)
+	}
+}
+
+func TestNotFoundIsNotRetried(t *testing.T) {
+	fake, c := start(t)
+	fake.NotFound("/tv/1396")
+
+	_, err := c.TV(context.Background(), 1396)
+	if !errors.Is(err, tmdb.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	st := c.Stats()
+	if st.Requests != 1 || st.NotFound != 1 || st.Errors != 0 {
+		t.Errorf("stats = %+v, want 1 request, 1 not found, 0 errors", st)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	fake, c := start(t)
+	fake.RequireAPIKey("another key")
+
+	_, err := c.TV(context.Background(), 1396)
+	var se *tmdb.StatusError
+	if !errors.As(err, &se) || se.Code != 401 {
+		t.Fatalf("err = %v, want a 401 StatusError", err)
+	}
+	if n := fake.Count("/tv/1396"); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestNetworkErrorsHideAPIKey(t *testing.T) {
+	srv := tmdbtest.New().Start()
+	srv.Close() // connections are refused
+	const key = "secret-api-key"
+	c := tmdb.New(tmdb.Config{APIKey: key, BaseURL: srv.URL, MaxRetries: 1, Backoff: time.Millisecond})
+
+	_, err := c.TV(context.Background(), 1396)
+	if err == nil {
+		t.Fatal("TV succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), key) {
+		t.Errorf("error %q contains the API key", err)
+	}
+}
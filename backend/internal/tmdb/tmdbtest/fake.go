@@ -0,0 +1,442 @@
+// Package tmdbtest is an offline stand-in for the TMDB API, for tests and
+// local development.
+//
+// A Fake serves recorded responses from fixture files laid out like the API:
+// fixtures/tv/1396/season/1.json answers GET /tv/1396/season/1, and
+// fixtures/find/tt0903747.json answers GET /find/tt0903747. The bundled
+// fixtures are loaded by New; LoadDir adds more. On top of the fixtures a Fake
+// can simulate the failures the real API produces: 404s, shows TMDB lists as
+// one flat season, rate limiting with Retry-After, 5xx errors and slow
+// responses. It also stands in for image.tmdb.org (see serveImage).
+//
+// In tests, Start a Fake and point a client at it:
+//
+//	fake := tmdbtest.New()
+//	srv := fake.Start()
+//	defer srv.Close()
+//	tmdbAPI = tmdbtest.NewClient(srv)
+//
+// cmd/fake-tmdb serves a Fake standalone, for running the server or the sync
+// commands against it with TMDB_BASE_URL.
+package tmdbtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+//go:embed fixtures
+var fixturesFS embed.FS
+
+// Fake is an http.Handler answering TMDB v3 requests. It is safe for
+// concurrent use, and its settings may be changed while it serves.
+type Fake struct {
+	mu        sync.Mutex
+	responses map[string][]byte // API path -> body
+	notFound  map[string]bool
+	flat      map[int]bool // TMDB show ids served as one flat season
+	apiKey    string
+
+	latency          time.Duration
+	failNext         int // requests left to fail with failStatus
+	failStatus       int
+	retryAfter       time.Duration
+	rateLimitEvery   int
+	rateLimitRetry   time.Duration
+	requestsReceived int
+
+	record *recorder
+	log    []string
+}
+
+// New returns a Fake serving the bundled fixtures.
+func New() *Fake {
+	f := &Fake{responses: map[string][]byte{}, notFound: map[string]bool{}, flat: map[int]bool{}}
+	sub, err := fs.Sub(fixturesFS, "fixtures")
+	if err != nil {
+		panic(err)
+	}
+	if err := f.loadFS(sub); err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// LoadDir adds the fixtures under dir, replacing bundled ones with the same path.
+func (f *Fake) LoadDir(dir string) error {
+	return f.loadFS(os.DirFS(dir))
+}
+
+func (f *Fake) loadFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.responses["/"+strings.TrimSuffix(p, ".json")] = body
+		f.mu.Unlock()
+		return nil
+	})
+}
+
+// Set serves v, encoded as JSON, at an API path such as "/tv/1396".
+func (f *Fake) Set(apiPath string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[apiPath] = body
+	delete(f.notFound, apiPath)
+}
+
+// NotFound makes an API path 404 even if it has a fixture.
+func (f *Fake) NotFound(apiPath string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notFound[apiPath] = true
+}
+
+// FlatSeasons serves a show the way TMDB lists many anime and daily shows:
+// every regular episode in one season 1, numbered straight through, and a
+// 404 for seasons 2 and up. Specials (season 0) are unchanged.
+func (f *Fake) FlatSeasons(tvID int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flat[tvID] = true
+}
+
+// RequireAPIKey makes requests without api_key=key fail with 401.
+func (f *Fake) RequireAPIKey(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.apiKey = key
+}
+
+// SetLatency delays every response by d.
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// RateLimit answers the next n requests with 429 and a Retry-After of
+// retryAfter (rounded up to whole seconds, as TMDB sends it).
+func (f *Fake) RateLimit(n int, retryAfter time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext, f.failStatus, f.retryAfter = n, http.StatusTooManyRequests, retryAfter
+}
+
+// RateLimitEvery answers every nth request with 429, until called with 0.
+func (f *Fake) RateLimitEvery(n int, retryAfter time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rateLimitEvery, f.rateLimitRetry = n, retryAfter
+}
+
+// Fail answers the next n requests with status, e.g. 503.
+func (f *Fake) Fail(n, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext, f.failStatus, f.retryAfter = n, status, 0
+}
+
+// Requests lists the paths requested so far, in order, with their queries
+// minus api_key.
+func (f *Fake) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+// Count is how many requests so far had a path starting with prefix.
+func (f *Fake) Count(prefix string) int {
+	n := 0
+	for _, p := range f.Requests() {
+		if strings.HasPrefix(p, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// Start serves f on a local httptest server. Close it when done.
+func (f *Fake) Start() *httptest.Server {
+	return httptest.NewServer(f)
+}
+
+// NewClient returns a tmdb.Client for srv that retries after milliseconds
+// rather than seconds, so simulated failures don't slow tests down.
+// Retry-After from RateLimit is still honoured.
+func NewClient(srv *httptest.Server) *tmdb.Client {
+	return tmdb.New(tmdb.Config{
+		APIKey:            "test",
+		BaseURL:           srv.URL,
+		RequestsPerSecond: 1000,
+		Burst:             100,
+		Backoff:           time.Millisecond,
+	})
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := q.Get("api_key")
+	q.Del("api_key")
+	logged := r.URL.Path
+	if len(q) > 0 {
+		logged += "?" + q.Encode()
+	}
+
+	f.mu.Lock()
+	f.log = append(f.log, logged)
+	f.requestsReceived++
+	latency := f.latency
+	status, retryAfter := 0, time.Duration(0)
+	switch {
+	case f.failNext > 0:
+		f.failNext--
+		status, retryAfter = f.failStatus, f.retryAfter
+	case f.rateLimitEvery > 0 && f.requestsReceived%f.rateLimitEvery == 0:
+		status, retryAfter = http.StatusTooManyRequests, f.rateLimitRetry
+	}
+	isImage := strings.HasPrefix(r.URL.Path, "/t/p/")
+	wrongKey := f.apiKey != "" && key != f.apiKey && !isImage // images need no key
+	rec := f.record
+	f.mu.Unlock()
+
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		select {
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if wrongKey {
+		writeError(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+		return
+	}
+	if status != 0 {
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+		}
+		if status == http.StatusTooManyRequests {
+			writeError(w, status, 25, "Your request count is over the allowed limit.")
+		} else {
+			writeError(w, status, 11, "Internal error: Something went wrong, contact TMDB.")
+		}
+		return
+	}
+
+	if isImage {
+		f.serveImage(w, r)
+		return
+	}
+
+	body, ok, forced := f.lookup(r.URL.Path)
+	if !ok && !forced && rec != nil {
+		body, ok = rec.fetch(f, r.URL.Path, q)
+	}
+	if !ok {
+		if strings.HasPrefix(r.URL.Path, "/find/") && !forced {
+			// TMDB answers an unknown IMDb id with empty results, not a 404
+			body = []byte(`{"movie_results":[],"person_results":[],"tv_results":[],"tv_episode_results":[],"tv_season_results":[]}`)
+		} else {
+			writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Write(body)
+}
+
+var (
+	seasonPath  = regexp.MustCompile(`^/tv/(\d+)/season/(\d+)$`)
+	episodePath = regexp.MustCompile(`^/tv/(\d+)/season/(\d+)/episode/(\d+)$`)
+)
+
+// lookup finds the body for an API path: a fixture, a flattened season, or
+// an episode cut out of its season's fixture. forced reports a simulated
+// 404, from NotFound or a flattened show.
+func (f *Fake) lookup(apiPath string) (body []byte, ok, forced bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.notFound[apiPath] {
+		return nil, false, true
+	}
+	if m := seasonPath.FindStringSubmatch(apiPath); m != nil {
+		tvID, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		if f.flat[tvID] && n > 0 {
+			if n > 1 {
+				return nil, false, true
+			}
+			body, ok = f.flatSeason(tvID)
+			return body, ok, false
+		}
+	}
+	if body, ok := f.responses[apiPath]; ok {
+		return body, true, false
+	}
+	if m := episodePath.FindStringSubmatch(apiPath); m != nil {
+		tvID, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		e, _ := strconv.Atoi(m[3])
+		var season []byte
+		if f.flat[tvID] && n > 0 {
+			if n > 1 {
+				return nil, false, true
+			}
+			season, ok = f.flatSeason(tvID)
+		} else {
+			season, ok = f.responses[fmt.Sprintf("/tv/%d/season/%d", tvID, n)]
+		}
+		if !ok {
+			return nil, false, false
+		}
+		var s struct {
+			Episodes []map[string]any `json:"episodes"`
+		}
+		if json.Unmarshal(season, &s) != nil {
+			return nil, false, false
+		}
+		for _, ep := range s.Episodes {
+			if num, _ := ep["episode_number"].(float64); int(num) == e {
+				body, _ := json.Marshal(ep)
+				return body, true, false
+			}
+		}
+		// The season is known, so the episode definitely isn't
+		return nil, false, true
+	}
+	return nil, false, false
+}
+
+// flatSeason concatenates a show's season fixtures from 1 up into one
+// season 1, renumbering episodes. f.mu must be held.
+func (f *Fake) flatSeason(tvID int) ([]byte, bool) {
+	prefix := fmt.Sprintf("/tv/%d/season/", tvID)
+	var numbers []int
+	for p := range f.responses {
+		if n, err := strconv.Atoi(strings.TrimPrefix(p, prefix)); err == nil && strings.HasPrefix(p, prefix) && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, false
+	}
+	sort.Ints(numbers)
+
+	var flat map[string]any
+	var episodes []map[string]any
+	for _, n := range numbers {
+		var s map[string]any
+		if json.Unmarshal(f.responses[fmt.Sprintf("%s%d", prefix, n)], &s) != nil {
+			continue
+		}
+		if flat == nil {
+			flat = s
+		}
+		raw, _ := json.Marshal(s["episodes"])
+		var eps []map[string]any
+		json.Unmarshal(raw, &eps)
+		for _, ep := range eps {
+			ep["season_number"] = 1
+			ep["episode_number"] = len(episodes) + 1
+			episodes = append(episodes, ep)
+		}
+	}
+	if flat == nil {
+		return nil, false
+	}
+	flat["name"], flat["season_number"], flat["episodes"] = "Season 1", 1, episodes
+	body, err := json.Marshal(flat)
+	return body, err == nil
+}
+
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"success": false, "status_code": code, "status_message": msg})
+}
+
+// recorder fetches fixture misses from the real API and saves them.
+type recorder struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	dir     string
+}
+
+// RecordFrom makes f fetch any path it has no fixture for from the API at
+// baseURL, serve the response, and save a 200 under dir so later runs are
+// offline. Use it to record new fixtures.
+func (f *Fake) RecordFrom(baseURL, apiKey, dir string) {
+	if baseURL == "" {
+		baseURL = tmdb.DefaultBaseURL
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record = &recorder{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		dir:     dir,
+	}
+}
+
+func (rec *recorder) fetch(f *Fake, apiPath string, q url.Values) ([]byte, bool) {
+	query := url.Values{"api_key": {rec.apiKey}}
+	for k, v := range q {
+		query[k] = v
+	}
+	u := rec.baseURL + apiPath + "?" + query.Encode()
+	resp, err := rec.client.Get(u)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, false
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false
+	}
+	file := filepath.Join(rec.dir, filepath.FromSlash(path.Clean(apiPath))+".json")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err == nil {
+		os.WriteFile(file, body, 0o644)
+	}
+	f.mu.Lock()
+	f.responses[apiPath] = body
+	f.mu.Unlock()
+	return body, true
+}
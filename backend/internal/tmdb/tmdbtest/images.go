@@ -0,0 +1,54 @@
+package tmdbtest
+
+import (
+	"bytes"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// imagePath is an image.tmdb.org path: /t/p/{size}/{file}
+var imagePath = regexp.MustCompile(`^/t/p/(original|w\d+)/[A-Za-z0-9_-]+\.(jpg|png)$`)
+
+// serveImage answers image.tmdb.org requests with a generated image: a solid
+// colour derived from the file name, 2:3 like a poster, the size's width wide
+// (600 for original). .png files get a transparent border, like logos. Point
+// an image origin at {fake}/t/p/original.
+func (f *Fake) serveImage(w http.ResponseWriter, r *http.Request) {
+	m := imagePath.FindStringSubmatch(r.URL.Path)
+	_, _, forced := f.lookup(r.URL.Path)
+	if m == nil || forced {
+		http.NotFound(w, r)
+		return
+	}
+	width := 600
+	if m[1] != "original" {
+		width, _ = strconv.Atoi(m[1][1:])
+	}
+	width = min(max(width, 1), 4000)
+
+	h := fnv.New32a()
+	h.Write([]byte(r.URL.Path[len("/t/p/"+m[1]):]))
+	sum := h.Sum32()
+	c := color.RGBA{uint8(sum), uint8(sum >> 8), uint8(sum >> 16), 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, width*3/2))
+	var buf bytes.Buffer
+	if m[2] == "png" {
+		inner := img.Bounds().Inset(width / 10)
+		draw.Draw(img, inner, image.NewUniform(c), image.Point{}, draw.Src)
+		png.Encode(&buf, img)
+		w.Header().Set("Content-Type", "image/png")
+	} else {
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		jpeg.Encode(&buf, img, nil)
+		w.Header().Set("Content-Type", "image/jpeg")
+	}
+	w.Write(buf.Bytes())
+}
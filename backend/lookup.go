@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// External ID lookup
+//
+// Integrations usually hold an IMDb tconst or a TMDB id rather than our
+// title_id. /api/lookup resolves either to the movie or show it belongs to.
+// TMDB numbers movies and TV separately, so a tmdb_id needs a type.
+
+const maxLookupBatch = 1000
+
+var imdbIDPattern = regexp.MustCompile(`^tt\d+$`)
+
+// LookupKey is one external ID to resolve: an imdb_id, or a tmdb_id plus type
+type LookupKey struct {
+	IMDbID string `json:"imdb_id,omitempty"`
+	TMDBID int    `json:"tmdb_id,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// LookupResult pairs a requested key with the title it resolved to, if any
+type LookupResult struct {
+	LookupKey
+	Found bool               `json:"found"`
+	Error string             `json:"error,omitempty"`
+	Title *TitleSearchResult `json:"title,omitempty"`
+}
+
+// normalize validates k, lower-casing the tconst and mapping TMDB's "tv" to "show".
+func (k *LookupKey) normalize() error {
+	k.IMDbID = strings.ToLower(strings.TrimSpace(k.IMDbID))
+	k.Type = strings.ToLower(strings.TrimSpace(k.Type))
+	if k.Type == "tv" {
+		k.Type = "show"
+	}
+	switch {
+	case k.IMDbID != "" && k.TMDBID != 0:
+		return fmt.Errorf("give either imdb_id or tmdb_id, not both")
+	case k.IMDbID != "":
+		if !imdbIDPattern.MatchString(k.IMDbID) {
+			return fmt.Errorf("invalid imdb_id")
+		}
+		if k.Type != "" && k.Type != "movie" && k.Type != "show" {
+			return fmt.Errorf("type must be movie or show")
+		}
+	case k.TMDBID > 0:
+		if k.Type != "movie" && k.Type != "show" {
+			return fmt.Errorf("tmdb_id requires type=movie or type=show")
+		}
+	default:
+		return fmt.Errorf("imdb_id or tmdb_id is required")
+	}
+	return nil
+}
+
+// lookupTitles resolves keys with one query per ID kind. Keys must
+// already be normalized. The result maps "imdb:<tconst>" and
+// "tmdb:<type>:<id>" to the matching title; if several titles share a TMDB id,
+// the most-voted one wins.
+func lookupTitles(keys []LookupKey) (map[string]TitleSearchResult, error) {
+	var imdbIDs []string
+	var tmdbMovies, tmdbShows []int64
+	for _, k := range keys {
+		switch {
+		case k.IMDbID != "":
+			imdbIDs = append(imdbIDs, k.IMDbID)
+		case k.Type == "movie":
+			tmdbMovies = append(tmdbMovies, int64(k.TMDBID))
+		default:
+			tmdbShows = append(tmdbShows, int64(k.TMDBID))
+		}
+	}
+
+	found := make(map[string]TitleSearchResult)
+	selectCols := `
+		SELECT t.id, t.type, t.display_name, t.start_year, t.end_year, t.imdb_id, t.image_url, t.tmdb_id,
+		       s.id, m.id, t.num_votes, t.average_rating, t.original_title, t.original_language,
+		       TO_CHAR(t.release_date, 'YYYY-MM-DD'), t.created_at, t.updated_at, t.poster_path, t.image_blurhash, t.image_color
+		FROM titles t
+		LEFT JOIN shows s ON s.title_id = t.id
+		LEFT JOIN movies m ON m.title_id = t.id`
+	scan := func(keyOf func(TitleSearchResult) string, query string, args ...any) error {
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var t TitleSearchResult
+			rows.Scan(&t.TitleID, &t.Type, &t.DisplayName, &t.StartYear, &t.EndYear, &t.IMDbID, &t.ImageURL, &t.TMDBID,
+				&t.ShowID, &t.MovieID, &t.NumVotes, &t.AverageRating, &t.OriginalTitle, &t.OriginalLanguage,
+				&t.ReleaseDate, &t.CreatedAt, &t.UpdatedAt, &t.PosterPath, &t.ImageBlurhash, &t.ImageColor)
+			if !hasImage(t.ImageURL) {
+				t.ImageURL = nil
+			}
+			if key := keyOf(t); found[key].TitleID == 0 {
+				found[key] = t
+			}
+		}
+		return rows.Err()
+	}
+
+	if len(imdbIDs) > 0 {
+		err := scan(func(t TitleSearchResult) string { return "imdb:" + *t.IMDbID },
+			selectCols+` WHERE t.imdb_id = ANY($1)`, pq.Array(imdbIDs))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, group := range []struct {
+		typ string
+		ids []int64
+	}{{"movie", tmdbMovies}, {"show", tmdbShows}} {
+		if len(group.ids) == 0 {
+			continue
+		}
+		err := scan(func(t TitleSearchResult) string { return "tmdb:" + t.Type + ":" + strconv.Itoa(*t.TMDBID) },
+			selectCols+` WHERE t.type = $1 AND t.tmdb_id = ANY($2) ORDER BY t.num_votes DESC NULLS LAST`,
+			group.typ, pq.Array(group.ids))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+func (k LookupKey) mapKey() string {
+	if k.IMDbID != "" {
+		return "imdb:" + k.IMDbID
+	}
+	return "tmdb:" + k.Type + ":" + strconv.Itoa(k.TMDBID)
+}
+
+// handleAPILookup serves GET /api/lookup?imdb_id= or ?tmdb_id=&type= with the
+// full Movie or Show payload (or a 302 to it with ?redirect=true), and
+// POST /api/lookup for batches of up to 1000 keys.
+func handleAPILookup(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
+		k := LookupKey{IMDbID: q.Get("imdb_id"), Type: q.Get("type")}
+		if s := q.Get("tmdb_id"); s != "" {
+			id, err := strconv.Atoi(s)
+			if err != nil || id <= 0 {
+				jsonError(w, "invalid tmdb_id", 400)
+				return
+			}
+			k.TMDBID = id
+		}
+		if err := k.normalize(); err != nil {
+			jsonError(w, err.Error(), 400)
+			return
+		}
+		found, err := lookupTitles([]LookupKey{k})
+		if err != nil {
+			jsonError(w, "Database error", 500)
+			return
+		}
+		t, ok := found[k.mapKey()]
+		if !ok || (k.Type != "" && t.Type != k.Type) {
+			jsonError(w, "Not found", 404)
+			return
+		}
+
+		if q.Get("redirect") == "true" {
+			target := fmt.Sprintf("/api/titles/%d", t.TitleID)
+			if t.MovieID != nil {
+				target = fmt.Sprintf("/api/movies/%d", *t.MovieID)
+			} else if t.ShowID != nil {
+				target = fmt.Sprintf("/api/shows/%d", *t.ShowID)
+			}
+			fwd := url.Values{}
+			for _, name := range []string{"region", "include", "image_size"} {
+				if v := q.Get(name); v != "" {
+					fwd.Set(name, v)
+				}
+			}
+			if len(fwd) > 0 {
+				target += "?" + fwd.Encode()
+			}
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+
+		region := strings.ToUpper(q.Get("region"))
+		switch {
+		case t.MovieID != nil:
+			movie, err := getMovieByID(*t.MovieID)
+			if err != nil {
+				jsonError(w, "Not found", 404)
+				return
+			}
+			// Lookups come from integrations syncing libraries, not from
+			// people opening a title, so they queue behind page views
+			enrichTitle(&movie.Title, priorityList)
+			movie.Credits, movie.CreditsTotal = loadCreditsForTitle(movie.TitleID, creditLimit(r))
+			applyRegion(&movie.Title, region)
+			movie.Title.applyImages(imageSize)
+			go logEngagement(movie.Title.TitleID, q.Get("source"))
+			jsonResponse(w, movie)
+		case t.ShowID != nil:
+			show, err := getShowByID(*t.ShowID, true)
+			if err != nil {
+				jsonError(w, "Not found", 404)
+				return
+			}
+			enrichShow(&show, priorityList)
+			show.Credits, show.CreditsTotal = loadCreditsForTitle(show.TitleID, creditLimit(r))
+			applyRegion(&show.Title, region)
+			show.applyImages(imageSize)
+			go logEngagement(show.Title.TitleID, q.Get("source"))
+			jsonResponse(w, show)
+		default:
+			title, err := getTitleByID(t.TitleID)
+			if err != nil {
+				jsonError(w, "Not found", 404)
+				return
+			}
+			applyRegion(&title, region)
+			title.applyImages(imageSize)
+			jsonResponse(w, title)
+		}
+
+	case "POST":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			IDs []LookupKey `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid JSON", 400)
+			return
+		}
+		if len(req.IDs) > maxLookupBatch {
+			jsonError(w, fmt.Sprintf("at most %d ids per request", maxLookupBatch), 400)
+			return
+		}
+
+		results := make([]LookupResult, len(req.IDs))
+		var valid []LookupKey
+		for i := range req.IDs {
+			k := req.IDs[i]
+			if err := k.normalize(); err != nil {
+				results[i] = LookupResult{LookupKey: req.IDs[i], Error: err.Error()}
+				continue
+			}
+			results[i].LookupKey = k
+			valid = append(valid, k)
+		}
+
+		found, err := lookupTitles(valid)
+		if err != nil {
+			jsonError(w, "Database error", 500)
+			return
+		}
+		matched := 0
+		var missing []int
+		for i := range results {
+			if results[i].Error != "" {
+				continue
+			}
+			if t, ok := found[results[i].mapKey()]; ok && (results[i].Type == "" || t.Type == results[i].Type) {
+				results[i].Found = true
+				if needsFetch(t.ImageURL, t.IMDbID) {
+					missing = append(missing, t.TitleID)
+				}
+				t.applyImages(imageSize)
+				results[i].Title = &t
+				matched++
+			}
+		}
+		// Queue missing images for the enrichment workers in one go; they show
+		// on the next lookup
+		blocked := enrichmentBlocked(entityTitle, missing)
+		missing = slices.DeleteFunc(missing, func(id int) bool { return blocked[id] })
+		queueEnrichment(jobTitle, priorityList, missing...)
+		jsonResponse(w, map[string]any{"results": results, "found": matched, "total": len(results)})
+
+	default:
+		w.WriteHeader(405)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +27,8 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"fyne.io/systray"
+
+	"mediacanon.org/backend/internal/tmdb"
 )
 
 //go:embed templates/*.html
@@ -40,11 +44,11 @@ var iconPNG []byte
 var collectionsFS embed.FS
 
 var (
-	db         *sql.DB
-	tmpls      map[string]*template.Template
-	tmdbAPIKey string
-	logFile    *os.File
-	logPath    string
+	db      *sql.DB
+	tmpls   map[string]*template.Template
+	tmdbAPI *tmdb.Client // nil-safe; see tmdbAPI.Enabled
+	logFile *os.File
+	logPath string
 
 	// Carousel cache: "type:genre" -> top titles + total count
 	carouselCache   map[string]carouselBucket
@@ -72,11 +76,29 @@ type Title struct {
 	OriginCountry      *string   `json:"origin_country,omitempty"`
 	NeedsBackfillTMDB  bool       `json:"-"`
 	EpisodesCheckedAt  *time.Time `json:"-"`
+	PendingEnrichment  bool       `json:"pending_enrichment,omitempty"` // TMDB enrichment queued; see enrichment.go
+	PosterPath         *string    `json:"-"` // TMDB file paths; see images.go
+	BackdropPath       *string    `json:"-"`
+	LogoPath           *string    `json:"-"`
+	Images             ImageSet   `json:"images,omitempty"`
+	ImageBlurhash      *string    `json:"image_blurhash,omitempty"` // poster placeholder; see placeholders.go
+	ImageColor         *string    `json:"image_color,omitempty"`
+	PlaceholderPath    *string    `json:"-"`
 	Genres             []string  `json:"genres,omitempty"`
+	Akas               []TitleAka `json:"akas,omitempty"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 
+// TitleAka is an alternate (localized) title from IMDb title.akas
+type TitleAka struct {
+	Title      string   `json:"title"`
+	Region     *string  `json:"region,omitempty"`
+	Language   *string  `json:"language,omitempty"`
+	Types      []string `json:"types,omitempty"`
+	IsOriginal bool     `json:"is_original"`
+}
+
 // DiscoverTitle is a lightweight struct for poster grid display
 type DiscoverTitle struct {
 	TitleID          int      `json:"title_id"`
@@ -84,6 +106,8 @@ type DiscoverTitle struct {
 	DisplayName      string   `json:"display_name"`
 	StartYear        *int     `json:"start_year,omitempty"`
 	ImageURL         *string  `json:"image_url,omitempty"`
+	ImageBlurhash    *string  `json:"image_blurhash,omitempty"`
+	ImageColor       *string  `json:"image_color,omitempty"`
 	MovieID          *int     `json:"movie_id,omitempty"`
 	ShowID           *int     `json:"show_id,omitempty"`
 	AverageRating    *float64 `json:"average_rating,omitempty"`
@@ -135,6 +159,10 @@ type TitleSearchResult struct {
 	EndYear          *int      `json:"end_year,omitempty"`
 	IMDbID           *string   `json:"imdb_id,omitempty"`
 	ImageURL         *string   `json:"image_url,omitempty"`
+	PosterPath       *string   `json:"-"`
+	Images           ImageSet  `json:"images,omitempty"`
+	ImageBlurhash    *string   `json:"image_blurhash,omitempty"`
+	ImageColor       *string   `json:"image_color,omitempty"`
 	TMDBID           *int      `json:"tmdb_id,omitempty"`
 	ShowID           *int      `json:"show_id,omitempty"`
 	MovieID          *int      `json:"movie_id,omitempty"`
@@ -148,9 +176,11 @@ type TitleSearchResult struct {
 }
 
 type Movie struct {
-	MovieID int   `json:"movie_id"`
-	TitleID int   `json:"title_id"`
-	Title   Title `json:"title"`
+	MovieID      int      `json:"movie_id"`
+	TitleID      int      `json:"title_id"`
+	Title        Title    `json:"title"`
+	Credits      []Credit `json:"credits,omitempty"`
+	CreditsTotal int      `json:"credits_total,omitempty"`
 }
 
 type Show struct {
@@ -159,54 +189,62 @@ type Show struct {
 	Title            Title    `json:"title"`
 	Seasons          []Season `json:"seasons,omitempty"`
 	IsSeriesFinished *bool    `json:"is_series_finished"`
+	Status           *string  `json:"status,omitempty"` // TMDB status, e.g. "Returning Series", "Ended"
+	InProduction     *bool    `json:"in_production,omitempty"`
+	NextAirDate      *string  `json:"next_air_date,omitempty"`
+	Orderings        []string `json:"orderings,omitempty"` // alternate episode orderings, see orderings.go
+	Order            string   `json:"order,omitempty"`     // ordering Seasons is grouped by, if not our own
+	Credits          []Credit `json:"credits,omitempty"`
+	CreditsTotal     int      `json:"credits_total,omitempty"`
+
+	nextEpisode, lastEpisode showEpisodeRef // TMDB next/last episode to air, see show_status.go
 }
 
 type Season struct {
 	SeasonID         int       `json:"season_id"`
 	ShowID           int       `json:"show_id"`
 	SeasonNumber     int       `json:"season_number"`
+	DisplayName      *string   `json:"display_name,omitempty"`
+	Overview         *string   `json:"overview,omitempty"`
+	ImageURL         *string   `json:"image_url,omitempty"`
+	PosterPath       *string   `json:"-"`
+	Images           ImageSet  `json:"images,omitempty"`
+	AirDate          *string   `json:"air_date,omitempty"`
+	EpisodeCount     *int      `json:"episode_count,omitempty"`
 	Episodes         []Episode `json:"episodes,omitempty"`
 	IsSeasonFinished *bool     `json:"is_season_finished"`
 }
 
+// seasonColumns selects a Season from show_seasons; scan into dests().
+const seasonColumns = `id, show_id, season, display_name, overview, image_url, poster_path, TO_CHAR(air_date, 'YYYY-MM-DD'), episode_count`
+
+func (s *Season) dests() []any {
+	return []any{&s.SeasonID, &s.ShowID, &s.SeasonNumber, &s.DisplayName, &s.Overview, &s.ImageURL, &s.PosterPath, &s.AirDate, &s.EpisodeCount}
+}
+
 type Episode struct {
-	EpisodeID      int     `json:"episode_id"`
-	SeasonID       int     `json:"season_id"`
-	EpisodeNumber  int     `json:"episode_number"`
-	DisplayName    *string `json:"display_name,omitempty"`
-	ImageURL       *string `json:"image_url,omitempty"`
-	AirDate        *string `json:"air_date,omitempty"`
-	RuntimeMinutes *int    `json:"runtime_minutes,omitempty"`
-	Synopsis       *string `json:"synopsis,omitempty"`
-}
-
-// TMDB types for on-demand image fetching
-
-type TMDBFindResponse struct {
-	TVResults []struct {
-		ID               int      `json:"id"`
-		PosterPath       string   `json:"poster_path"`
-		OriginalLanguage string   `json:"original_language"`
-		FirstAirDate     string   `json:"first_air_date"`
-		Popularity       float64  `json:"popularity"`
-		OriginCountry    []string `json:"origin_country"`
-	} `json:"tv_results"`
-	MovieResults []struct {
-		ID               int      `json:"id"`
-		PosterPath       string   `json:"poster_path"`
-		OriginalLanguage string   `json:"original_language"`
-		ReleaseDate      string   `json:"release_date"`
-		Popularity       float64  `json:"popularity"`
-		OriginCountry    []string `json:"origin_country"`
-	} `json:"movie_results"`
-}
-
-type TMDBEpisodeResponse struct {
-	Name      string `json:"name"`
-	Overview  string `json:"overview"`
-	StillPath string `json:"still_path"`
-	AirDate   string `json:"air_date"`
-	Runtime   int    `json:"runtime"`
+	EpisodeID      int      `json:"episode_id"`
+	SeasonID       int      `json:"season_id"`
+	EpisodeNumber  int      `json:"episode_number"`
+	DisplayName    *string  `json:"display_name,omitempty"`
+	ImageURL       *string  `json:"image_url,omitempty"`
+	StillPath      *string  `json:"-"`
+	Images         ImageSet `json:"images,omitempty"`
+	ImageBlurhash  *string  `json:"image_blurhash,omitempty"`
+	ImageColor     *string  `json:"image_color,omitempty"`
+	AirDate        *string  `json:"air_date,omitempty"`
+	RuntimeMinutes *int     `json:"runtime_minutes,omitempty"`
+	Synopsis       *string  `json:"synopsis,omitempty"`
+	IMDbID         *string  `json:"imdb_id,omitempty"`
+	NumVotes       *int     `json:"num_votes,omitempty"`
+	AverageRating  *float64 `json:"average_rating,omitempty"`
+}
+
+// episodeColumns selects an Episode from show_episodes; scan into dests().
+const episodeColumns = `id, season_id, episode, display_name, image_url, still_path, image_blurhash, image_color, TO_CHAR(air_date, 'YYYY-MM-DD'), runtime_minutes, synopsis, imdb_id, num_votes, average_rating`
+
+func (e *Episode) dests() []any {
+	return []any{&e.EpisodeID, &e.SeasonID, &e.EpisodeNumber, &e.DisplayName, &e.ImageURL, &e.StillPath, &e.ImageBlurhash, &e.ImageColor, &e.AirDate, &e.RuntimeMinutes, &e.Synopsis, &e.IMDbID, &e.NumVotes, &e.AverageRating}
 }
 
 func main() {
@@ -216,8 +254,18 @@ func main() {
 func onReady() {
 	setupLogging()
 
-	tmdbAPIKey = os.Getenv("TMDB_API_KEY")
-	if tmdbAPIKey != "" {
+	// The server gets a small share of the TMDB quota by default, leaving
+	// the rest for cmd/sync and cmd/sync-images running alongside it
+	rps, burst := tmdb.RateFromEnv(10)
+	tmdbAPI = tmdb.New(tmdb.Config{
+		APIKey:            os.Getenv("TMDB_API_KEY"),
+		BaseURL:           os.Getenv("TMDB_BASE_URL"),
+		RequestsPerSecond: rps,
+		Burst:             burst,
+	})
+	loadImageSizes()
+	setupImageCache()
+	if tmdbAPI.Enabled() {
 		log.Println("TMDB API key configured — on-demand image fetching enabled")
 	}
 
@@ -229,6 +277,18 @@ func onReady() {
 		"add":         func(a, b int) int { return a + b },
 		"subtract":    func(a, b int) int { return a - b },
 		"join": strings.Join,
+		"img": func(kind string, id int, url any) string {
+			// A template image URL, through the image proxy if enabled
+			switch u := url.(type) {
+			case string:
+				return proxyImageURL(kind, id, u)
+			case *string:
+				if u != nil {
+					return proxyImageURL(kind, id, *u)
+				}
+			}
+			return ""
+		},
 		"derefStr": func(p *string) string {
 			if p == nil {
 				return ""
@@ -287,7 +347,7 @@ func onReady() {
 		},
 	}
 	tmpls = make(map[string]*template.Template)
-	pages := []string{"home", "titles", "movie", "show", "add", "search", "api", "discover"}
+	pages := []string{"home", "titles", "movie", "show", "person", "add", "search", "api", "discover"}
 	for _, page := range pages {
 		t, err := template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/base.html", "templates/"+page+".html")
 		if err != nil {
@@ -321,6 +381,18 @@ func onReady() {
 	// Build carousel cache (one query for all discover page data)
 	buildCarouselCache()
 
+	// Typeahead index; rebuilt after cmd/sync runs (checked every 5 minutes)
+	buildSuggestIndex()
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		for range ticker.C {
+			refreshSuggestIndex()
+		}
+	}()
+
+	// TMDB enrichment queued by handlers (see enrichment.go)
+	startEnrichmentWorkers(enrichmentWorkerCount())
+
 	// Daily cleanup of old view/click tracking data
 	go func() {
 		cleanupOldViews()
@@ -340,6 +412,9 @@ func onReady() {
 		http.FileServer(http.FS(staticFS)).ServeHTTP(w, r)
 	})
 
+	// Cached images — immutable, so cacheable for good (see image_proxy.go)
+	mux.HandleFunc("/img/", handleImage)
+
 	// Disable caching on all non-static responses
 	noCache := func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -356,6 +431,8 @@ func onReady() {
 	mux.HandleFunc("/titles", noCache(handleTitlesList))
 	mux.HandleFunc("/movies/", noCache(handleMoviePage))
 	mux.HandleFunc("/shows/", noCache(handleShowPage))
+	mux.HandleFunc("/people/", noCache(handlePersonPage))
+	mux.HandleFunc("/calendar.ics", noCache(handleListCalendar))
 	mux.HandleFunc("/add", noCache(handleAddPage))
 	mux.HandleFunc("/api", noCache(handleAPIPage))
 	mux.HandleFunc("/api/", noCache(handleAPISlash))
@@ -363,6 +440,8 @@ func onReady() {
 	// API - Titles
 	mux.HandleFunc("/api/titles", noCache(handleAPITitles))
 	mux.HandleFunc("/api/titles/", noCache(handleAPITitle))
+	mux.HandleFunc("/api/suggest", noCache(handleAPISuggest))
+	mux.HandleFunc("/api/lookup", noCache(handleAPILookup))
 
 	// API - Movies
 	mux.HandleFunc("/api/movies", noCache(handleAPIMoviesCreate))
@@ -376,7 +455,14 @@ func onReady() {
 	mux.HandleFunc("/api/seasons/", noCache(handleAPISeason))
 
 	// API - Episodes
+	mux.HandleFunc("/api/episodes", noCache(handleAPIEpisodes))
 	mux.HandleFunc("/api/episodes/", noCache(handleAPIEpisode))
+	mux.HandleFunc("/api/calendar", noCache(handleAPICalendar))
+	mux.HandleFunc("/api/tmdb/stats", noCache(handleAPITMDBStats))
+
+	// API - People
+	mux.HandleFunc("/api/people", noCache(handleAPIPeople))
+	mux.HandleFunc("/api/people/", noCache(handleAPIPerson))
 
 	// API - Discover & Collections
 	mux.HandleFunc("/api/discover/carousels", noCache(handleAPIDiscoverCarousels))
@@ -509,33 +595,18 @@ func getLocalIP() string {
 
 // TMDB on-demand image fetching
 
-var tmdbClient = &http.Client{Timeout: 10 * time.Second}
-
-func fetchAndStoreTMDBImage(imdbID, titleType string) (string, int) {
-	if tmdbAPIKey == "" || imdbID == "" {
+// fetchAndStoreTMDBImage resolves an IMDb id on TMDB and stores the poster,
+// TMDB id and basic metadata, and the attempt's enrichment status: not_found
+// when TMDB has no poster, error when the request failed.
+func fetchAndStoreTMDBImage(ctx context.Context, titleID int, imdbID, titleType string) (string, int) {
+	if !tmdbAPI.Enabled() || imdbID == "" {
 		return "", 0
 	}
 
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/find/%s?api_key=%s&external_source=imdb_id",
-		imdbID, tmdbAPIKey,
-	)
-
-	resp, err := tmdbClient.Get(url)
+	result, err := tmdbAPI.Find(ctx, imdbID)
 	if err != nil {
 		log.Printf("TMDB fetch error for %s: %v", imdbID, err)
-		return "", 0
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Printf("TMDB returned status %d for %s", resp.StatusCode, imdbID)
-		return "", 0
-	}
-
-	var result TMDBFindResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("TMDB decode error for %s: %v", imdbID, err)
+		recordEnrichment(entityTitle, []int{titleID}, statusError, err.Error())
 		return "", 0
 	}
 
@@ -543,113 +614,113 @@ func fetchAndStoreTMDBImage(imdbID, titleType string) (string, int) {
 	var tmdbID int
 	var origLang, releaseDate, originCountry string
 	var popularity float64
+	useTV := func(tv tmdb.FindTV) {
+		tmdbID, origLang, releaseDate, popularity = tv.ID, tv.OriginalLanguage, tv.FirstAirDate, tv.Popularity
+		if len(tv.OriginCountry) > 0 {
+			originCountry = tv.OriginCountry[0]
+		}
+	}
+	useMovie := func(mv tmdb.FindMovie) {
+		tmdbID, origLang, releaseDate, popularity = mv.ID, mv.OriginalLanguage, mv.ReleaseDate, mv.Popularity
+		if len(mv.OriginCountry) > 0 {
+			originCountry = mv.OriginCountry[0]
+		}
+	}
 	if titleType == "show" && len(result.TVResults) > 0 {
 		posterPath = result.TVResults[0].PosterPath
-		tmdbID = result.TVResults[0].ID
-		origLang = result.TVResults[0].OriginalLanguage
-		releaseDate = result.TVResults[0].FirstAirDate
-		popularity = result.TVResults[0].Popularity
-		if len(result.TVResults[0].OriginCountry) > 0 {
-			originCountry = result.TVResults[0].OriginCountry[0]
-		}
+		useTV(result.TVResults[0])
 	} else if titleType == "movie" && len(result.MovieResults) > 0 {
 		posterPath = result.MovieResults[0].PosterPath
-		tmdbID = result.MovieResults[0].ID
-		origLang = result.MovieResults[0].OriginalLanguage
-		releaseDate = result.MovieResults[0].ReleaseDate
-		popularity = result.MovieResults[0].Popularity
-		if len(result.MovieResults[0].OriginCountry) > 0 {
-			originCountry = result.MovieResults[0].OriginCountry[0]
-		}
+		useMovie(result.MovieResults[0])
 	}
 	// Fallback: check both result types
 	if posterPath == "" && len(result.MovieResults) > 0 {
 		posterPath = result.MovieResults[0].PosterPath
 		if tmdbID == 0 {
-			tmdbID = result.MovieResults[0].ID
-			origLang = result.MovieResults[0].OriginalLanguage
-			releaseDate = result.MovieResults[0].ReleaseDate
-			popularity = result.MovieResults[0].Popularity
-			if len(result.MovieResults[0].OriginCountry) > 0 {
-				originCountry = result.MovieResults[0].OriginCountry[0]
-			}
+			useMovie(result.MovieResults[0])
 		}
 	}
 	if posterPath == "" && len(result.TVResults) > 0 {
 		posterPath = result.TVResults[0].PosterPath
 		if tmdbID == 0 {
-			tmdbID = result.TVResults[0].ID
-			origLang = result.TVResults[0].OriginalLanguage
-			releaseDate = result.TVResults[0].FirstAirDate
-			popularity = result.TVResults[0].Popularity
-			if len(result.TVResults[0].OriginCountry) > 0 {
-				originCountry = result.TVResults[0].OriginCountry[0]
-			}
+			useTV(result.TVResults[0])
 		}
 	}
 
-	// TMDB Find API often omits origin_country for movies — fetch from details API
-	if originCountry == "" && tmdbID != 0 && tmdbAPIKey != "" {
-		detailURL := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?api_key=%s", tmdbID, tmdbAPIKey)
+	// The details API has the backdrop and logo, and the origin_country the
+	// Find API often omits for movies
+	var backdropPath, logoPath string
+	if tmdbID != 0 {
+		var detail *tmdb.Details
 		if titleType == "show" {
-			detailURL = fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?api_key=%s", tmdbID, tmdbAPIKey)
-		}
-		if dresp, derr := http.Get(detailURL); derr == nil {
-			defer dresp.Body.Close()
-			if dresp.StatusCode == 200 {
-				var detail struct {
-					OriginCountry       []string `json:"origin_country"`
-					ProductionCountries []struct {
-						ISO string `json:"iso_3166_1"`
-					} `json:"production_countries"`
-				}
-				if json.NewDecoder(dresp.Body).Decode(&detail) == nil {
-					if len(detail.OriginCountry) > 0 {
-						originCountry = detail.OriginCountry[0]
-					} else if len(detail.ProductionCountries) > 0 {
-						originCountry = detail.ProductionCountries[0].ISO
-					}
-				}
+			if tv, err := tmdbAPI.TV(ctx, tmdbID); err == nil {
+				detail = &tv.Details
 			}
+		} else if mv, err := tmdbAPI.Movie(ctx, tmdbID); err == nil {
+			detail = &mv.Details
+		}
+		if detail != nil {
+			if originCountry == "" {
+				originCountry = detail.Country()
+			}
+			backdropPath, logoPath = detail.BackdropPath, detail.LogoPath()
 		}
 	}
 
 	if posterPath == "" {
-		// Cache the miss so we don't re-fetch
-		db.Exec(`UPDATE titles SET image_url = 'none', tmdb_id = $1,
+		// Store what we did learn; the retry schedule decides when to look again
+		recordEnrichment(entityTitle, []int{titleID}, statusNotFound, "")
+		db.Exec(`UPDATE titles SET tmdb_id = $1,
 			original_language = COALESCE(NULLIF($3, ''), original_language),
 			release_date = CASE WHEN $4 = '' THEN release_date ELSE $4::date END,
 			tmdb_popularity = $5,
 			origin_country = COALESCE(NULLIF($6, ''), origin_country),
+			backdrop_path = COALESCE(NULLIF($7, ''), backdrop_path),
+			logo_path = COALESCE(NULLIF($8, ''), logo_path),
 			needs_backfill_tmdb = false
-			WHERE imdb_id = $2`, tmdbID, imdbID, origLang, releaseDate, popularity, originCountry)
+			WHERE imdb_id = $2`, tmdbID, imdbID, origLang, releaseDate, popularity, originCountry, backdropPath, logoPath)
 		return "", tmdbID
 	}
 
-	imageURL := "https://image.tmdb.org/t/p/w500" + posterPath
+	imageURL := tmdb.ImageURL("w500", posterPath)
 	_, err = db.Exec(`UPDATE titles SET image_url = $1, tmdb_id = $2,
 		original_language = COALESCE(NULLIF($4, ''), original_language),
 		release_date = CASE WHEN $5 = '' THEN release_date ELSE $5::date END,
 		tmdb_popularity = $6,
 		origin_country = COALESCE(NULLIF($7, ''), origin_country),
+		poster_path = $8,
+		backdrop_path = COALESCE(NULLIF($9, ''), backdrop_path),
+		logo_path = COALESCE(NULLIF($10, ''), logo_path),
 		needs_backfill_tmdb = false
-		WHERE imdb_id = $3`, imageURL, tmdbID, imdbID, origLang, releaseDate, popularity, originCountry)
+		WHERE imdb_id = $3`, imageURL, tmdbID, imdbID, origLang, releaseDate, popularity, originCountry, posterPath, backdropPath, logoPath)
 	if err != nil {
 		log.Printf("Failed to store TMDB image for %s: %v", imdbID, err)
 	} else {
 		log.Printf("Fetched TMDB image for %s (tmdb_id=%d)", imdbID, tmdbID)
+		recordEnrichment(entityTitle, []int{titleID}, statusFound, "")
 	}
 	return imageURL, tmdbID
 }
 
+// handleAPITMDBStats reports the shared TMDB client's counters and the
+// enrichment queue length, so enrichment load can be watched against the quota.
+func handleAPITMDBStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+	jsonResponse(w, map[string]any{"enabled": tmdbAPI.Enabled(), "stats": tmdbAPI.Stats(), "queued_jobs": enrichmentQueueLength()})
+}
+
 // maybeFetchImage checks if a title needs an image and fetches from TMDB if so.
 // Updates the title's ImageURL in place.
-// hasImage returns true if the title has a real image (not the "TMDB_NOT_FOUND_DO_NOT_RETRY" sentinel)
+// hasImage returns true if the title has an image
 func hasImage(imageURL *string) bool {
-	return imageURL != nil && *imageURL != "" && *imageURL != "TMDB_NOT_FOUND_DO_NOT_RETRY"
+	return imageURL != nil && *imageURL != ""
 }
 
-// needsFetch returns true if the title has no image and hasn't been checked yet
+// needsFetch returns true if the title has no image and can be looked up on
+// TMDB. Whether a retry is due is enrichment_status's call; see titleImageDue.
 func needsFetch(imageURL *string, imdbID *string) bool {
 	if imdbID == nil || *imdbID == "" {
 		return false
@@ -659,11 +730,11 @@ func needsFetch(imageURL *string, imdbID *string) bool {
 
 // maybeFetchImage checks if a title needs an image and fetches from TMDB if so.
 // Updates the title's ImageURL in place.
-func maybeFetchImage(title *Title) {
-	if !needsFetch(title.ImageURL, title.IMDbID) {
+func maybeFetchImage(ctx context.Context, title *Title) {
+	if !titleImageDue(title) {
 		return
 	}
-	url, tmdbID := fetchAndStoreTMDBImage(*title.IMDbID, title.Type)
+	url, tmdbID := fetchAndStoreTMDBImage(ctx, title.TitleID, *title.IMDbID, title.Type)
 	if url != "" {
 		title.ImageURL = &url
 	} else {
@@ -676,8 +747,8 @@ func maybeFetchImage(title *Title) {
 
 // maybeTMDBBackfill re-fetches TMDB metadata when needs_backfill_tmdb is true.
 // Updates origin_country, image, popularity, language, release_date and clears the flag.
-func maybeTMDBBackfill(title *Title) {
-	if !title.NeedsBackfillTMDB || tmdbAPIKey == "" {
+func maybeTMDBBackfill(ctx context.Context, title *Title) {
+	if !title.NeedsBackfillTMDB || !tmdbAPI.Enabled() {
 		return
 	}
 	if title.IMDbID == nil || *title.IMDbID == "" {
@@ -695,19 +766,10 @@ func maybeTMDBBackfill(title *Title) {
 
 	// If we don't have a TMDB ID yet, look it up via Find API
 	if tmdbID == 0 {
-		findURL := fmt.Sprintf("https://api.themoviedb.org/3/find/%s?api_key=%s&external_source=imdb_id", imdbID, tmdbAPIKey)
-		resp, err := http.Get(findURL)
+		result, err := tmdbAPI.Find(ctx, imdbID)
 		if err != nil {
 			return
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return
-		}
-		var result TMDBFindResponse
-		if json.NewDecoder(resp.Body).Decode(&result) != nil {
-			return
-		}
 		if title.Type == "show" && len(result.TVResults) > 0 {
 			tmdbID = result.TVResults[0].ID
 		} else if title.Type == "movie" && len(result.MovieResults) > 0 {
@@ -726,54 +788,34 @@ func maybeTMDBBackfill(title *Title) {
 	}
 
 	// Call TMDB details API for full metadata
-	detailURL := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d?api_key=%s", tmdbID, tmdbAPIKey)
+	var detail tmdb.Details
+	var releaseDate string
+	var runtime float64
+	var err error
 	if title.Type == "show" {
-		detailURL = fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?api_key=%s", tmdbID, tmdbAPIKey)
-	}
-	dresp, err := http.Get(detailURL)
-	if err != nil {
-		return
+		var tv *tmdb.TV
+		if tv, err = tmdbAPI.TV(ctx, tmdbID); err == nil {
+			detail, releaseDate = tv.Details, tv.FirstAirDate
+		}
+	} else {
+		var mv *tmdb.Movie
+		if mv, err = tmdbAPI.Movie(ctx, tmdbID); err == nil {
+			detail, releaseDate, runtime = mv.Details, mv.ReleaseDate, mv.Runtime
+		}
 	}
-	defer dresp.Body.Close()
-	if dresp.StatusCode != 200 {
-		// Clear flag even on 404 so we don't retry forever
+	if errors.Is(err, tmdb.ErrNotFound) {
+		// Clear flag on 404 so we don't retry forever
 		db.Exec(`UPDATE titles SET needs_backfill_tmdb = false WHERE id = $1`, title.TitleID)
 		title.NeedsBackfillTMDB = false
 		return
 	}
-
-	var detail struct {
-		PosterPath          string   `json:"poster_path"`
-		OriginalLanguage    string   `json:"original_language"`
-		ReleaseDate         string   `json:"release_date"`
-		FirstAirDate        string   `json:"first_air_date"`
-		Popularity          float64  `json:"popularity"`
-		OriginCountry       []string `json:"origin_country"`
-		ProductionCountries []struct {
-			ISO string `json:"iso_3166_1"`
-		} `json:"production_countries"`
-		Runtime float64 `json:"runtime"`
-	}
-	if json.NewDecoder(dresp.Body).Decode(&detail) != nil {
+	if err != nil {
 		return
 	}
 
-	originCountry := ""
-	if len(detail.OriginCountry) > 0 {
-		originCountry = detail.OriginCountry[0]
-	} else if len(detail.ProductionCountries) > 0 {
-		originCountry = detail.ProductionCountries[0].ISO
-	}
-
-	releaseDate := detail.ReleaseDate
-	if releaseDate == "" {
-		releaseDate = detail.FirstAirDate
-	}
-
-	imageURL := ""
-	if detail.PosterPath != "" {
-		imageURL = "https://image.tmdb.org/t/p/w500" + detail.PosterPath
-	}
+	originCountry := detail.Country()
+	imageURL := tmdb.ImageURL("w500", detail.PosterPath)
+	logoPath := detail.LogoPath()
 
 	_, err = db.Exec(`UPDATE titles SET
 		tmdb_id = $1,
@@ -783,10 +825,14 @@ func maybeTMDBBackfill(title *Title) {
 		tmdb_popularity = CASE WHEN $5::real = 0 THEN tmdb_popularity ELSE $5::real END,
 		origin_country = COALESCE(NULLIF($6, ''), origin_country),
 		runtime_minutes = CASE WHEN $7::int = 0 THEN runtime_minutes ELSE $7::int END,
+		poster_path = COALESCE(NULLIF($9, ''), poster_path),
+		backdrop_path = COALESCE(NULLIF($10, ''), backdrop_path),
+		logo_path = COALESCE(NULLIF($11, ''), logo_path),
 		needs_backfill_tmdb = false
 		WHERE id = $8`,
 		tmdbID, imageURL, detail.OriginalLanguage, releaseDate,
-		detail.Popularity, originCountry, int(detail.Runtime), title.TitleID)
+		detail.Popularity, originCountry, int(runtime), title.TitleID,
+		detail.PosterPath, detail.BackdropPath, logoPath)
 
 	if err != nil {
 		log.Printf("TMDB backfill update failed for title %d: %v", title.TitleID, err)
@@ -803,108 +849,110 @@ func maybeTMDBBackfill(title *Title) {
 	}
 	if imageURL != "" {
 		title.ImageURL = &imageURL
+		title.PosterPath = &detail.PosterPath
+		recordEnrichment(entityTitle, []int{title.TitleID}, statusFound, "")
+	}
+	if detail.BackdropPath != "" {
+		title.BackdropPath = &detail.BackdropPath
+	}
+	if logoPath != "" {
+		title.LogoPath = &logoPath
 	}
 	if detail.Popularity > 0 {
 		title.TMDBPopularity = &detail.Popularity
 	}
 }
 
-// fetchAndStoreEpisodeData fetches episode data from TMDB and stores it in the DB.
-// Returns the fetched data so callers can update in-memory structs.
-func fetchAndStoreEpisodeData(tmdbID, seasonNum, episodeNum, episodeID int) (imageURL, airDate, displayName, synopsis string, runtime int, ok, notFound bool) {
-	if tmdbAPIKey == "" {
-		return
-	}
-
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/tv/%d/season/%d/episode/%d?api_key=%s",
-		tmdbID, seasonNum, episodeNum, tmdbAPIKey,
-	)
-
-	var resp *http.Response
-	var err error
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err = tmdbClient.Get(url)
-		if err != nil {
-			log.Printf("TMDB episode fetch error for S%dE%d: %v", seasonNum, episodeNum, err)
-			return
-		}
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			wait := time.Duration(2<<attempt) * time.Second // 2s, 4s, 8s
-			log.Printf("TMDB rate limited for S%dE%d, retrying in %v (attempt %d/3)", seasonNum, episodeNum, wait, attempt+1)
-			time.Sleep(wait)
-			continue
-		}
-		break
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 404 {
-		log.Printf("TMDB S%dE%d: 404 not found", seasonNum, episodeNum)
-		notFound = true
-		return
-	}
-	if resp.StatusCode != 200 {
-		log.Printf("TMDB episode returned status %d for S%dE%d", resp.StatusCode, seasonNum, episodeNum)
-		return
-	}
-
-	var ep TMDBEpisodeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ep); err != nil {
-		log.Printf("TMDB episode decode error for S%dE%d: %v", seasonNum, episodeNum, err)
-		return
+// fetchTMDBSeason fetches /tv/{id}/season/{n}, which carries the season's own
+// metadata and every episode in one response. notFound reports a 404, i.e.
+// TMDB has no such season (as opposed to a transient failure).
+func fetchTMDBSeason(ctx context.Context, tmdbID, seasonNum int) (season *tmdb.Season, notFound bool) {
+	s, err := tmdbAPI.Season(ctx, tmdbID, seasonNum)
+	if errors.Is(err, tmdb.ErrNotFound) {
+		log.Printf("TMDB S%d: 404 not found", seasonNum)
+		return nil, true
 	}
-
-	if ep.StillPath != "" {
-		imageURL = "https://image.tmdb.org/t/p/w400" + ep.StillPath
-	}
-	airDate = ep.AirDate
-	displayName = ep.Name
-	synopsis = ep.Overview
-	runtime = ep.Runtime
-
-	// Store a sentinel if no image, so we don't re-fetch
-	dbImageURL := imageURL
-	if dbImageURL == "" {
-		dbImageURL = "TMDB_NOT_FOUND_DO_NOT_RETRY"
-	}
-
-	_, err = db.Exec(`
-		UPDATE show_episodes
-		SET image_url = $1, air_date = CASE WHEN $2 = '' THEN air_date ELSE $2::date END,
-		    runtime_minutes = CASE WHEN $3 = 0 THEN runtime_minutes ELSE $3 END,
-		    display_name = COALESCE(NULLIF($4, ''), display_name),
-		    synopsis = $5
+	if err != nil {
+		log.Printf("TMDB season fetch error for S%d: %v", seasonNum, err)
+		return nil, false
+	}
+	return s, false
+}
+
+// storeTMDBSeason saves a season's name, overview, poster, air date and
+// episode count, and copies them onto season for immediate rendering.
+func storeTMDBSeason(season *Season, s *tmdb.Season) {
+	posterURL := tmdb.ImageURL("w500", s.PosterPath)
+	episodeCount := len(s.Episodes)
+	_, err := db.Exec(`
+		UPDATE show_seasons SET
+			display_name = COALESCE(NULLIF($1, ''), display_name),
+			overview = COALESCE(NULLIF($2, ''), overview),
+			image_url = COALESCE(NULLIF($3, ''), image_url),
+			poster_path = COALESCE(NULLIF($7, ''), poster_path),
+			air_date = CASE WHEN $4 = '' THEN air_date ELSE $4::date END,
+			episode_count = $5
 		WHERE id = $6
-	`, dbImageURL, airDate, runtime, displayName, synopsis, episodeID)
+	`, s.Name, s.Overview, posterURL, s.AirDate, episodeCount, season.SeasonID, s.PosterPath)
 	if err != nil {
-		log.Printf("Failed to store TMDB episode data for S%dE%d: %v", seasonNum, episodeNum, err)
+		log.Printf("Failed to store TMDB season data for season %d: %v", season.SeasonID, err)
 		return
 	}
+	for _, f := range []struct {
+		dst **string
+		v   string
+	}{{&season.DisplayName, s.Name}, {&season.Overview, s.Overview}, {&season.ImageURL, posterURL}, {&season.PosterPath, s.PosterPath}, {&season.AirDate, s.AirDate}} {
+		if f.v != "" {
+			v := f.v
+			*f.dst = &v
+		}
+	}
+	season.EpisodeCount = &episodeCount
+}
 
-	ok = true
-	return
+// tmdbEpisodeUpdate is one episode's TMDB data, ready to store
+type tmdbEpisodeUpdate struct {
+	episodeID int
+	imageURL  string // still URL, or "" to keep the stored one
+	data      tmdb.Episode
 }
 
-// stripEpisodeSentinels removes TMDB_NOT_FOUND_DO_NOT_RETRY sentinels from episode image URLs
-// so they render as missing images in the template.
-func stripEpisodeSentinels(show *Show) {
-	for si := range show.Seasons {
-		for ei := range show.Seasons[si].Episodes {
-			ep := &show.Seasons[si].Episodes[ei]
-			if ep.ImageURL != nil && *ep.ImageURL == "TMDB_NOT_FOUND_DO_NOT_RETRY" {
-				ep.ImageURL = nil
-			}
-		}
+// storeTMDBEpisodes writes a season's worth of episode data in one UPDATE.
+func storeTMDBEpisodes(updates []tmdbEpisodeUpdate) error {
+	if len(updates) == 0 {
+		return nil
 	}
+	values := make([]string, len(updates))
+	args := make([]any, 0, len(updates)*7)
+	for i, u := range updates {
+		b := i * 7
+		values[i] = fmt.Sprintf("($%d::int, $%d::text, NULLIF($%d::text, '')::date, NULLIF($%d::int, 0), NULLIF($%d::text, ''), NULLIF($%d::text, ''), NULLIF($%d::text, ''))", b+1, b+2, b+3, b+4, b+5, b+6, b+7)
+		args = append(args, u.episodeID, u.imageURL, u.data.AirDate, u.data.Runtime, u.data.Name, u.data.Overview, u.data.StillPath)
+	}
+	_, err := db.Exec(`
+		UPDATE show_episodes e SET
+			image_url = COALESCE(NULLIF(v.image_url, ''), e.image_url),
+			still_path = COALESCE(v.still_path, e.still_path),
+			air_date = COALESCE(v.air_date, e.air_date),
+			runtime_minutes = COALESCE(v.runtime, e.runtime_minutes),
+			display_name = COALESCE(v.name, e.display_name),
+			synopsis = COALESCE(v.synopsis, e.synopsis)
+		FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, image_url, air_date, runtime, name, synopsis, still_path)
+		WHERE e.id = v.id
+	`, args...)
+	return err
 }
 
 // maybeFetchEpisodes fetches episode data from TMDB for episodes missing data.
-// Uses a 24-hour cooldown (episodes_checked_at) to avoid hammering TMDB on every page visit,
-// while still retrying episodes that previously had no image available.
-func maybeFetchEpisodes(show *Show) {
-	if tmdbAPIKey == "" {
+// It makes one /tv/{id}/season/{n} request per TMDB season that has a gap,
+// which also refreshes the season's own metadata, and records which TMDB
+// episode each of ours matched as the "aired" ordering. A run that fetches
+// anything also checks the show's TMDB episode groups (see
+// storeTMDBEpisodeGroups); every run refreshes the show's TMDB status. Uses a 24-hour cooldown
+// (episodes_checked_at) to avoid hammering TMDB on every page visit; episodes
+// TMDB had no still for are retried on their enrichment_status schedule.
+func maybeFetchEpisodes(ctx context.Context, show *Show) {
+	if !tmdbAPI.Enabled() {
 		return
 	}
 	if show.Title.IMDbID == nil || *show.Title.IMDbID == "" {
@@ -918,7 +966,7 @@ func maybeFetchEpisodes(show *Show) {
 	}
 	if tmdbID == 0 {
 		// Call fetchAndStoreTMDBImage to resolve the TMDB ID
-		_, id := fetchAndStoreTMDBImage(*show.Title.IMDbID, "show")
+		_, id := fetchAndStoreTMDBImage(ctx, show.Title.TitleID, *show.Title.IMDbID, "show")
 		tmdbID = id
 		if tmdbID != 0 {
 			show.Title.TMDBID = &tmdbID
@@ -928,157 +976,236 @@ func maybeFetchEpisodes(show *Show) {
 		return
 	}
 
-	// Daily cooldown: if we checked recently, just strip sentinels and return
+	// Daily cooldown: if we checked recently, there's nothing to do
 	if show.Title.EpisodesCheckedAt != nil &&
 		time.Since(*show.Title.EpisodesCheckedAt) < 24*time.Hour {
-		stripEpisodeSentinels(show)
 		return
 	}
 
-	// Collect episodes needing fetch (including sentinel values, which are now eligible for retry)
-	type epRef struct {
-		seasonIdx  int
-		episodeIdx int
-		seasonNum  int
-		episodeNum int
-		episodeID  int
+	// Status and next/last episode change without any episode data missing,
+	// so they are refreshed on every cooldown expiry (see show_status.go)
+	if st, err := tmdbAPI.TV(ctx, tmdbID); err != nil {
+		log.Printf("TMDB status fetch failed for %s: %v", show.Title.DisplayName, err)
+	} else {
+		storeTMDBShowStatus(show, st)
+	}
+
+	// Our episodes are matched to TMDB's aired numbering once and the match
+	// is stored (see orderings.go). Episodes needing data are looked up at
+	// their stored aired position, or at our own position if not matched yet.
+	// Episodes without a still are retried once their enrichment_status
+	// retry is due. Episodes aired in the last week or still to come are
+	// refetched every time, since schedules shift and stills appear after
+	// airing (see calendar.go).
+	recent := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
+	var imageless []int
+	for _, season := range show.Seasons {
+		for _, ep := range season.Episodes {
+			if !hasImage(ep.ImageURL) {
+				imageless = append(imageless, ep.EpisodeID)
+			}
+		}
+	}
+	blocked := enrichmentBlocked(entityEpisode, imageless)
+	episodeNeedsData := func(ep Episode) bool {
+		return (!hasImage(ep.ImageURL) && !blocked[ep.EpisodeID]) ||
+			(ep.AirDate != nil && *ep.AirDate >= recent)
+	}
+	aired := loadEpisodeOrdering(show.ShowID, "aired")
+	absolute := loadEpisodeOrdering(show.ShowID, "absolute")
+	if !absoluteCurrent(show, absolute) {
+		if err := refreshAbsoluteOrdering(show.ShowID); err != nil {
+			log.Printf("Failed to refresh absolute ordering for %s: %v", show.Title.DisplayName, err)
+		}
+		absolute = loadEpisodeOrdering(show.ShowID, "absolute")
+	}
+
+	type episodeTarget struct {
+		season *Season
+		ep     *Episode
+		pos    orderingPos // TMDB aired position to look in
+		mapped bool        // pos is a stored match rather than a guess
 	}
-	var toFetch []epRef
-	for si, season := range show.Seasons {
-		for ei, ep := range season.Episodes {
-			if ep.ImageURL == nil || *ep.ImageURL == "" || *ep.ImageURL == "TMDB_NOT_FOUND_DO_NOT_RETRY" {
-				toFetch = append(toFetch, epRef{si, ei, season.SeasonNumber, ep.EpisodeNumber, ep.EpisodeID})
+	var targets []episodeTarget
+	tmdbSeasons := map[int]bool{}
+	for si := range show.Seasons {
+		season := &show.Seasons[si]
+		elsewhere := len(season.Episodes) > 0 // every episode matched to another TMDB season
+		for ei := range season.Episodes {
+			ep := &season.Episodes[ei]
+			pos, mapped := aired[ep.EpisodeID]
+			if !mapped || pos.Season == season.SeasonNumber {
+				elsewhere = false
 			}
+			if mapped && !episodeNeedsData(*ep) {
+				continue
+			}
+			if !mapped {
+				pos = orderingPos{season.SeasonNumber, ep.EpisodeNumber}
+			}
+			targets = append(targets, episodeTarget{season, ep, pos, mapped})
+			tmdbSeasons[pos.Season] = true
+		}
+		// Seasons never fetched (no episode_count yet) still need their metadata
+		if season.EpisodeCount == nil && !elsewhere {
+			tmdbSeasons[season.SeasonNumber] = true
 		}
 	}
 
-	if len(toFetch) == 0 {
-		stripEpisodeSentinels(show)
+	if len(tmdbSeasons) == 0 {
+		db.Exec(`UPDATE titles SET episodes_checked_at = NOW() WHERE id = $1`, show.Title.TitleID)
+		deriveFinishedFlags(show)
 		return
 	}
 
-	log.Printf("Fetching TMDB data for %d episodes of %s (tmdb_id=%d)", len(toFetch), show.Title.DisplayName, tmdbID)
-
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 5) // limit to 5 concurrent TMDB requests
-	type epResult struct {
-		ref                                            epRef
-		imageURL, airDate, displayName, synopsis string
-		runtime                                        int
-		ok, notFound                                   bool
-	}
-	results := make([]epResult, len(toFetch))
-
-	for i, ref := range toFetch {
-		wg.Add(1)
-		go func(i int, ref epRef) {
-			defer wg.Done()
-			sem <- struct{}{}        // acquire
-			defer func() { <-sem }() // release
-			imgURL, airDate, name, synopsis, rt, ok, nf := fetchAndStoreEpisodeData(tmdbID, ref.seasonNum, ref.episodeNum, ref.episodeID)
-			results[i] = epResult{ref, imgURL, airDate, name, synopsis, rt, ok, nf}
-		}(i, ref)
-	}
-	wg.Wait()
-
-	// Omniseason fallback: if all episodes for a season returned 404, TMDB likely uses
-	// a flat season structure (e.g. one big "Season 1"). Retry by computing the absolute
-	// episode number from our known previous season episode counts.
-	// Build a map of season number → count of 404s and total episodes to fetch
-	type seasonStats struct{ notFound, total int }
-	seasonNotFound := map[int]*seasonStats{}
-	for _, res := range results {
-		sn := res.ref.seasonNum
-		if seasonNotFound[sn] == nil {
-			seasonNotFound[sn] = &seasonStats{}
-		}
-		seasonNotFound[sn].total++
-		if res.notFound {
-			seasonNotFound[sn].notFound++
-		}
-	}
-
-	// Build cumulative episode offset per season from our data
-	// e.g. season 1 has 24 eps, season 2 has 24 eps → offset for season 3 = 48
-	episodeOffset := map[int]int{}
-	cumulative := 0
-	for _, s := range show.Seasons {
-		episodeOffset[s.SeasonNumber] = cumulative
-		cumulative += len(s.Episodes)
-	}
-
-	// Retry 404'd seasons using omniseason mapping (TMDB season 1 + absolute episode num)
-	var omniRetry []int // indices into results
-	for i, res := range results {
-		if !res.notFound {
-			continue
+	log.Printf("Fetching TMDB data for %d seasons of %s (tmdb_id=%d)", len(tmdbSeasons), show.Title.DisplayName, tmdbID)
+
+	var mu sync.Mutex
+	results := map[int]*tmdb.Season{}
+	notFound := map[int]bool{}
+	fetchSeasons := func(numbers []int) {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, 5) // tmdbAPI rate-limits; this just bounds goroutines
+		for _, n := range numbers {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				sem <- struct{}{}        // acquire
+				defer func() { <-sem }() // release
+				s, nf := fetchTMDBSeason(ctx, tmdbID, n)
+				mu.Lock()
+				results[n], notFound[n] = s, nf
+				mu.Unlock()
+			}(n)
+		}
+		wg.Wait()
+	}
+	numbers := make([]int, 0, len(tmdbSeasons))
+	for n := range tmdbSeasons {
+		numbers = append(numbers, n)
+	}
+	fetchSeasons(numbers)
+
+	// Flat-season fallback: an unmatched episode whose season TMDB doesn't
+	// have is looked up by its absolute number in TMDB season 1 (e.g. TMDB
+	// lists one big "Season 1"). A hit is stored as its aired position, so
+	// later fetches go straight to season 1.
+	for _, t := range targets {
+		if !t.mapped && notFound[t.pos.Season] && t.pos.Season != 1 {
+			if _, done := results[1]; !done {
+				log.Printf("Trying flat-season fallback for %s (mapping to TMDB S1)", show.Title.DisplayName)
+				fetchSeasons([]int{1})
+			}
+			break
 		}
-		stats := seasonNotFound[res.ref.seasonNum]
-		if stats == nil || stats.notFound != stats.total {
-			continue // only retry if the entire season 404'd
-		}
-		if res.ref.seasonNum == 1 {
-			continue // season 1 already tried, no point in omniseason
-		}
-		omniRetry = append(omniRetry, i)
-	}
-
-	if len(omniRetry) > 0 {
-		log.Printf("Trying omniseason fallback for %d episodes of %s (mapping to TMDB S1)", len(omniRetry), show.Title.DisplayName)
-		var wg2 sync.WaitGroup
-		for _, idx := range omniRetry {
-			wg2.Add(1)
-			go func(idx int) {
-				defer wg2.Done()
-				sem <- struct{}{}
-				defer func() { <-sem }()
-				ref := results[idx].ref
-				absEp := episodeOffset[ref.seasonNum] + ref.episodeNum
-				imgURL, airDate, name, synopsis, rt, ok, _ := fetchAndStoreEpisodeData(tmdbID, 1, absEp, ref.episodeID)
-				if ok {
-					results[idx] = epResult{ref, imgURL, airDate, name, synopsis, rt, true, false}
-				}
-			}(idx)
+	}
+
+	for si := range show.Seasons {
+		if s := results[show.Seasons[si].SeasonNumber]; s != nil {
+			storeTMDBSeason(&show.Seasons[si], s)
 		}
-		wg2.Wait()
 	}
 
-	// Update in-memory structs for immediate rendering
+	// Store results and update in-memory structs for immediate rendering
+	byNumber := map[int]map[int]tmdb.Episode{}
+	for n, s := range results {
+		if s != nil {
+			byNumber[n] = s.EpisodesByNumber()
+		}
+	}
+	matched := map[int]orderingPos{}
+	var unmatched []int
+	updates := map[*Season][]tmdbEpisodeUpdate{}
+	status := map[string][]int{} // enrichment status -> episodes still without a still
 	fetched, failed := 0, 0
-	for _, res := range results {
-		if !res.ok {
-			// Store sentinel for episodes that failed even after omniseason retry
-			if res.notFound {
-				sentinel := "TMDB_NOT_FOUND_DO_NOT_RETRY"
-				db.Exec(`UPDATE show_episodes SET image_url = $1 WHERE id = $2`, sentinel, res.ref.episodeID)
+	for _, t := range targets {
+		pos := t.pos
+		if !t.mapped && notFound[pos.Season] && pos.Season != 1 {
+			if abs, ok := absolute[t.ep.EpisodeID]; ok {
+				pos = orderingPos{1, abs.Episode}
+			}
+		}
+		// Only a definite answer from TMDB is not_found; anything else is an
+		// error, retried sooner
+		definite := results[pos.Season] != nil || notFound[pos.Season]
+
+		data, ok := byNumber[pos.Season][pos.Episode]
+		if !ok {
+			if definite {
+				if t.mapped {
+					unmatched = append(unmatched, t.ep.EpisodeID) // TMDB renumbered; match again next time
+				}
+			}
+			if !hasImage(t.ep.ImageURL) {
+				st := statusError
+				if definite {
+					st = statusNotFound
+				}
+				status[st] = append(status[st], t.ep.EpisodeID)
 			}
 			failed++
 			continue
 		}
+		if !t.mapped {
+			matched[t.ep.EpisodeID] = pos
+		}
+		if !episodeNeedsData(*t.ep) {
+			continue // matched only
+		}
+		ep := t.ep
+		u := tmdbEpisodeUpdate{episodeID: ep.EpisodeID, data: data}
+		if data.StillPath != "" {
+			u.imageURL = tmdb.ImageURL("w400", data.StillPath)
+			ep.ImageURL = &u.imageURL
+			ep.StillPath = &data.StillPath
+			status[statusFound] = append(status[statusFound], ep.EpisodeID)
+		} else if !hasImage(ep.ImageURL) {
+			status[statusNotFound] = append(status[statusNotFound], ep.EpisodeID)
+		}
+		updates[t.season] = append(updates[t.season], u)
 		fetched++
-		ep := &show.Seasons[res.ref.seasonIdx].Episodes[res.ref.episodeIdx]
-		if res.imageURL != "" {
-			ep.ImageURL = &res.imageURL
+
+		if data.AirDate != "" {
+			ep.AirDate = &data.AirDate
 		}
-		if res.airDate != "" {
-			ep.AirDate = &res.airDate
+		if data.Name != "" && ep.DisplayName == nil {
+			ep.DisplayName = &data.Name
 		}
-		if res.displayName != "" && ep.DisplayName == nil {
-			ep.DisplayName = &res.displayName
+		if data.Overview != "" {
+			ep.Synopsis = &data.Overview
 		}
-		if res.synopsis != "" {
-			ep.Synopsis = &res.synopsis
+		if data.Runtime != 0 {
+			ep.RuntimeMinutes = &data.Runtime
 		}
-		if res.runtime != 0 {
-			ep.RuntimeMinutes = &res.runtime
+	}
+	for season, u := range updates {
+		if err := storeTMDBEpisodes(u); err != nil {
+			log.Printf("Failed to store TMDB episode data for %s S%d: %v", show.Title.DisplayName, season.SeasonNumber, err)
 		}
 	}
-	log.Printf("TMDB episode fetch done for %s: %d succeeded, %d failed out of %d", show.Title.DisplayName, fetched, failed, len(toFetch))
+	for st, ids := range status {
+		recordEnrichment(entityEpisode, ids, st, "")
+	}
+	log.Printf("TMDB episode fetch done for %s: %d succeeded, %d failed", show.Title.DisplayName, fetched, failed)
+
+	if err := storeEpisodeOrdering(show.ShowID, "aired", matched, false); err != nil {
+		log.Printf("Failed to store aired ordering for %s: %v", show.Title.DisplayName, err)
+	}
+	if err := deleteEpisodeOrdering("aired", unmatched); err != nil {
+		log.Printf("Failed to drop stale aired positions for %s: %v", show.Title.DisplayName, err)
+	}
+	for id, pos := range matched {
+		aired[id] = pos
+	}
+	for _, id := range unmatched {
+		delete(aired, id)
+	}
+	storeTMDBEpisodeGroups(ctx, show, tmdbID, aired, len(matched) > 0 || len(unmatched) > 0)
 
 	// Update the timestamp so we don't re-fetch within 24 hours
 	db.Exec(`UPDATE titles SET episodes_checked_at = NOW() WHERE id = $1`, show.Title.TitleID)
 
-	stripEpisodeSentinels(show)
+	deriveFinishedFlags(show)
 }
 
 // Page Handlers
@@ -1114,7 +1241,12 @@ type TitleListItem struct {
 }
 
 func handleTitlesList(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query().Get("q")
+	if r.URL.Query().Get("tab") == "episodes" {
+		handleEpisodeSearchPage(w, r)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
 	typeFilter := r.URL.Query().Get("type")
 	langFilter := r.URL.Query().Get("lang")
 
@@ -1127,54 +1259,73 @@ func handleTitlesList(w http.ResponseWriter, r *http.Request) {
 
 	var items []TitleListItem
 
-	// Build WHERE clause (shared between count and data queries)
-	where := ` WHERE 1=1`
-	var args []any
-	argNum := 1
-
-	if q != "" {
-		where += ` AND t.display_name ILIKE '%' || $` + strconv.Itoa(argNum) + ` || '%'`
-		args = append(args, q)
-		argNum++
+	// The search box accepts the filter syntax from query.go; a bad query is
+	// shown above an empty result list rather than failing the page
+	f, qerr := parseTitleQuery(q)
+	queryErr := ""
+	if qerr != nil {
+		queryErr = qerr.Error()
+		f = titleFilter{}
 	}
 	if typeFilter != "" {
-		where += ` AND t.type = $` + strconv.Itoa(argNum)
-		args = append(args, typeFilter)
-		argNum++
+		f.Type = typeFilter
 	}
 	if langFilter != "" {
-		where += ` AND t.original_language = $` + strconv.Itoa(argNum)
-		args = append(args, langFilter)
-		argNum++
+		f.Langs = []string{langFilter}
 	}
 
+	// Build WHERE clause (shared between count and data queries)
+	where := newSQLWhere("")
+	relevance := f.apply(where)
+	keyset := titleKeyset("relevance", relevance)
+
 	// Total count
 	var total int
-	db.QueryRow(`SELECT COUNT(*) FROM titles t`+where, args...).Scan(&total)
+	if qerr == nil {
+		db.QueryRow(`SELECT COUNT(*) FROM titles t`+where.String(), where.args...).Scan(&total)
+	}
+
+	// Next links carry a cursor so deep pages don't pay for OFFSET; page is
+	// kept alongside it only for the "Page N of M" label
+	pageWhere := where.clone()
+	if cursor, err := decodeCursor(r.URL.Query().Get("cursor"), "relevance", keyset); err == nil {
+		keyset.after(pageWhere, cursor)
+		offset = 0
+	}
 
 	query := `
 		SELECT
 			m.id as movie_id, s.id as show_id,
 			t.id as title_id, t.type, t.display_name, t.start_year,
 			t.imdb_id, t.image_url, t.original_language,
-			t.num_votes, t.average_rating
+			t.num_votes, t.average_rating` + keyset.selectKeys() + `
 		FROM titles t
 		LEFT JOIN movies m ON m.title_id = t.id
-		LEFT JOIN shows s ON s.title_id = t.id` + where
-	query += ` ORDER BY t.num_votes DESC NULLS LAST, t.display_name LIMIT ` + strconv.Itoa(perPage) + ` OFFSET ` + strconv.Itoa(offset)
+		LEFT JOIN shows s ON s.title_id = t.id` + pageWhere.String()
+	query += ` ORDER BY ` + keyset.orderBy() + ` LIMIT ` + strconv.Itoa(perPage+1) + ` OFFSET ` + strconv.Itoa(offset)
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		http.Error(w, "Database error", 500)
-		log.Println(err)
-		return
-	}
-	defer rows.Close()
+	var nextCursor string
+	if qerr == nil {
+		rows, err := db.Query(query, pageWhere.args...)
+		if err != nil {
+			http.Error(w, "Database error", 500)
+			log.Println(err)
+			return
+		}
+		defer rows.Close()
 
-	for rows.Next() {
-		var item TitleListItem
-		rows.Scan(&item.MovieID, &item.ShowID, &item.TitleID, &item.Type, &item.DisplayName, &item.StartYear, &item.IMDbID, &item.ImageURL, &item.OriginalLanguage, &item.NumVotes, &item.AverageRating)
-		items = append(items, item)
+		var lastKeys []any
+		keyPtrs, keyVals := keyDests(len(keyset.keys))
+		for rows.Next() {
+			var item TitleListItem
+			rows.Scan(append([]any{&item.MovieID, &item.ShowID, &item.TitleID, &item.Type, &item.DisplayName, &item.StartYear, &item.IMDbID, &item.ImageURL, &item.OriginalLanguage, &item.NumVotes, &item.AverageRating}, keyPtrs...)...)
+			if len(items) == perPage {
+				nextCursor = encodeCursor("relevance", lastKeys, items[len(items)-1].TitleID)
+				break
+			}
+			items = append(items, item)
+			lastKeys = append(lastKeys[:0], keyVals...)
+		}
 	}
 
 	// Count languages for filter chips (only when searching)
@@ -1184,20 +1335,17 @@ func handleTitlesList(w http.ResponseWriter, r *http.Request) {
 		Count   int
 	}
 	var langCounts []LangCount
-	if q != "" {
+	if q != "" && qerr == nil {
+		lf := f
+		lf.Langs = nil
+		langWhere := newSQLWhere("")
+		lf.apply(langWhere)
 		langQuery := `
 			SELECT COALESCE(t.original_language, ''), COUNT(*)
-			FROM titles t
-			WHERE t.display_name ILIKE '%' || $1 || '%'`
-		langArgs := []any{q}
-		langArgNum := 2
-		if typeFilter != "" {
-			langQuery += ` AND t.type = $` + strconv.Itoa(langArgNum)
-			langArgs = append(langArgs, typeFilter)
-		}
-		langQuery += ` GROUP BY t.original_language ORDER BY COUNT(*) DESC`
+			FROM titles t` + langWhere.String() + `
+			GROUP BY t.original_language ORDER BY COUNT(*) DESC`
 
-		langRows, err := db.Query(langQuery, langArgs...)
+		langRows, err := db.Query(langQuery, langWhere.args...)
 		if err == nil {
 			defer langRows.Close()
 			for langRows.Next() {
@@ -1211,47 +1359,46 @@ func handleTitlesList(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Fetch missing images from TMDB concurrently
-	type fetchResult struct {
-		idx int
-		url string
-	}
-	ch := make(chan fetchResult, len(items))
-	pending := 0
-	for i, item := range items {
-		if needsFetch(item.ImageURL, item.IMDbID) {
-			pending++
-			go func(idx int, imdbID, titleType string) {
-				url, _ := fetchAndStoreTMDBImage(imdbID, titleType)
-				ch <- fetchResult{idx, url}
-			}(i, *item.IMDbID, item.Type)
-		}
-	}
-	for range pending {
-		res := <-ch
-		if res.url != "" {
-			items[res.idx].ImageURL = &res.url
-		}
+	// Facet chips (language has its own row above)
+	var facetGroups []facetChipGroup
+	if q != "" && qerr == nil {
+		names := []string{"genre", "decade", "country", "rating"}
+		facetGroups = titleFacetChips(q, typeFilter, langFilter, names, computeFacets(names, f, ""))
 	}
 
-	// Strip "TMDB_NOT_FOUND_DO_NOT_RETRY" sentinel so templates don't render it
-	for i := range items {
-		if items[i].ImageURL != nil && *items[i].ImageURL == "TMDB_NOT_FOUND_DO_NOT_RETRY" {
-			items[i].ImageURL = nil
+	// Queue missing images for the enrichment workers; they show on the next load
+	var missing []int
+	for _, item := range items {
+		if needsFetch(item.ImageURL, item.IMDbID) {
+			missing = append(missing, item.TitleID)
 		}
 	}
+	blocked := enrichmentBlocked(entityTitle, missing)
+	missing = slices.DeleteFunc(missing, func(id int) bool { return blocked[id] })
+	queueEnrichment(jobTitle, priorityList, missing...)
 
 	totalPages := (total + perPage - 1) / perPage
 
+	// Typo fallback: offer close matches when the search found nothing
+	var suggestions []TitleSuggestion
+	if total == 0 && f.Text != "" {
+		suggestions = fetchTitleSuggestions(f.Text, f.Type, 3)
+	}
+
 	tmpls["titles"].ExecuteTemplate(w, "base", map[string]any{
-		"Titles":     items,
-		"Query":      q,
-		"Type":       typeFilter,
-		"Lang":       langFilter,
-		"LangCounts": langCounts,
-		"Page":       page,
-		"TotalPages": totalPages,
-		"Total":      total,
+		"Tab":         "titles",
+		"Titles":      items,
+		"Query":       q,
+		"QueryError":  queryErr,
+		"Type":        typeFilter,
+		"Lang":        langFilter,
+		"LangCounts":  langCounts,
+		"FacetGroups": facetGroups,
+		"Suggestions": suggestions,
+		"NextCursor":  nextCursor,
+		"Page":        page,
+		"TotalPages":  totalPages,
+		"Total":       total,
 	})
 }
 
@@ -1274,8 +1421,8 @@ func handleMoviePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	maybeFetchImage(&movie.Title)
-	maybeTMDBBackfill(&movie.Title)
+	enrichTitle(&movie.Title, priorityView)
+	movie.Credits, movie.CreditsTotal = loadCreditsForTitle(movie.TitleID, topBilledCredits)
 	go logEngagement(movie.Title.TitleID, r.URL.Query().Get("source"))
 
 	tmpls["movie"].ExecuteTemplate(w, "base", movie)
@@ -1287,6 +1434,7 @@ func handleShowPage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/titles?type=show", http.StatusFound)
 		return
 	}
+	idStr, sub, _ := strings.Cut(idStr, "/")
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -1294,15 +1442,23 @@ func handleShowPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /shows/:id/calendar.ics
+	if sub == "calendar.ics" {
+		handleShowCalendar(w, r, id)
+		return
+	} else if sub != "" {
+		http.NotFound(w, r)
+		return
+	}
+
 	show, err := getShowByID(id, true)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	maybeFetchImage(&show.Title)
-	maybeTMDBBackfill(&show.Title)
-	maybeFetchEpisodes(&show)
+	enrichShow(&show, priorityView)
+	show.Credits, show.CreditsTotal = loadCreditsForTitle(show.TitleID, topBilledCredits)
 	go logEngagement(show.Title.TitleID, r.URL.Query().Get("source"))
 
 	tmpls["show"].ExecuteTemplate(w, "base", show)
@@ -1337,9 +1493,42 @@ func handleAPITitles(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case "GET":
-		q := r.URL.Query().Get("q")
-		typeFilter := r.URL.Query().Get("type")
-		langFilter := r.URL.Query().Get("lang")
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		f, qerr := parseTitleQuery(q)
+		if qerr != nil {
+			queryErrorResponse(w, qerr)
+			return
+		}
+		// Explicit params override the same filter in q
+		if typeFilter := r.URL.Query().Get("type"); typeFilter != "" {
+			f.Type = typeFilter
+		}
+		if langFilter := r.URL.Query().Get("lang"); langFilter != "" {
+			f.Langs = []string{langFilter}
+		}
+
+		// Relevance is the default when searching; plain listings sort by votes
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy == "" {
+			if f.Text != "" {
+				sortBy = "relevance"
+			} else {
+				sortBy = "most_rated"
+			}
+		}
+		if !validTitleSort(sortBy) {
+			jsonError(w, "Invalid sort: "+sortBy, 400)
+			return
+		}
+		facetNames, err := parseFacets(r.URL.Query().Get("facets"))
+		if err != nil {
+			jsonError(w, err.Error(), 400)
+			return
+		}
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 
 		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 		if page < 1 {
@@ -1350,68 +1539,71 @@ func handleAPITitles(w http.ResponseWriter, r *http.Request) {
 			perPage = 100
 		}
 		offset := (page - 1) * perPage
+		withCount := countParam(r.URL.Query().Get("count"))
 
-		where := ` WHERE 1=1`
-		var args []any
-		argNum := 1
+		where := newSQLWhere("")
+		relevance := f.apply(where)
+		keyset := titleKeyset(sortBy, relevance)
 
-		if q != "" {
-			where += ` AND t.display_name ILIKE '%' || $` + strconv.Itoa(argNum) + ` || '%'`
-			args = append(args, q)
-			argNum++
-		}
-		if typeFilter != "" {
-			where += ` AND t.type = $` + strconv.Itoa(argNum)
-			args = append(args, typeFilter)
-			argNum++
-		}
-		if langFilter != "" {
-			where += ` AND t.original_language = $` + strconv.Itoa(argNum)
-			args = append(args, langFilter)
-			argNum++
+		// ?cursor= continues from a previous page's next_cursor instead of OFFSET
+		var cursor *pageCursor
+		if token := r.URL.Query().Get("cursor"); token != "" {
+			if cursor, err = decodeCursor(token, sortBy, keyset); err != nil {
+				jsonError(w, err.Error(), 400)
+				return
+			}
+			offset = 0
 		}
 
 		var total int
-		db.QueryRow(`SELECT COUNT(*) FROM titles t`+where, args...).Scan(&total)
-
-		// Language distribution across the full query (without lang filter)
-		langWhere := ` WHERE 1=1`
-		var langArgs []any
-		langArgNum := 1
-		if q != "" {
-			langWhere += ` AND t.display_name ILIKE '%' || $` + strconv.Itoa(langArgNum) + ` || '%'`
-			langArgs = append(langArgs, q)
-			langArgNum++
-		}
-		if typeFilter != "" {
-			langWhere += ` AND t.type = $` + strconv.Itoa(langArgNum)
-			langArgs = append(langArgs, typeFilter)
-		}
 		var languages []map[string]any
-		langRows, err := db.Query(`SELECT COALESCE(t.original_language, ''), COUNT(*) FROM titles t`+langWhere+` GROUP BY t.original_language ORDER BY COUNT(*) DESC`, langArgs...)
-		if err == nil {
-			defer langRows.Close()
-			for langRows.Next() {
-				var code string
-				var count int
-				langRows.Scan(&code, &count)
-				if code != "" {
-					languages = append(languages, map[string]any{"code": code, "count": count})
+		if withCount {
+			db.QueryRow(`SELECT COUNT(*) FROM titles t`+where.String(), where.args...).Scan(&total)
+
+			// Language distribution across the full query (without lang filter)
+			lf := f
+			lf.Langs = nil
+			langWhere := newSQLWhere("")
+			lf.apply(langWhere)
+			langRows, err := db.Query(`SELECT COALESCE(t.original_language, ''), COUNT(*) FROM titles t`+langWhere.String()+` GROUP BY t.original_language ORDER BY COUNT(*) DESC`, langWhere.args...)
+			if err == nil {
+				defer langRows.Close()
+				for langRows.Next() {
+					var code string
+					var count int
+					langRows.Scan(&code, &count)
+					if code != "" {
+						languages = append(languages, map[string]any{"code": code, "count": count})
+					}
 				}
 			}
 		}
 
+		// The page query adds the region and cursor args after the filter args
+		pageWhere := where.clone()
+
+		// ?region= swaps in the preferred local title
+		nameExpr := "t.display_name"
+		if region := strings.ToUpper(r.URL.Query().Get("region")); region != "" {
+			nameExpr = regionalNameExpr(len(pageWhere.args) + 1)
+			pageWhere.args = append(pageWhere.args, region)
+		}
+		if cursor != nil {
+			keyset.after(pageWhere, cursor)
+		}
+
 		query := `
-			SELECT t.id, t.type, t.display_name, t.start_year, t.end_year, t.imdb_id, t.image_url, t.tmdb_id,
+			SELECT t.id, t.type, ` + nameExpr + `, t.start_year, t.end_year, t.imdb_id, t.image_url, t.tmdb_id,
 			       m.id as movie_id, s.id as show_id,
 			       t.num_votes, t.average_rating, t.original_title, t.original_language,
-			       TO_CHAR(t.release_date, 'YYYY-MM-DD'), t.created_at, t.updated_at
+			       TO_CHAR(t.release_date, 'YYYY-MM-DD'), t.created_at, t.updated_at, t.poster_path, t.image_blurhash, t.image_color` + keyset.selectKeys() + `
 			FROM titles t
 			LEFT JOIN movies m ON m.title_id = t.id
-			LEFT JOIN shows s ON s.title_id = t.id` + where
-		query += ` ORDER BY t.num_votes DESC NULLS LAST, t.display_name LIMIT ` + strconv.Itoa(perPage) + ` OFFSET ` + strconv.Itoa(offset)
+			LEFT JOIN shows s ON s.title_id = t.id` + pageWhere.String()
+		// One extra row tells us whether there is a next page
+		query += ` ORDER BY ` + keyset.orderBy() + ` LIMIT ` + strconv.Itoa(perPage+1) + ` OFFSET ` + strconv.Itoa(offset)
 
-		rows, err := db.Query(query, args...)
+		rows, err := db.Query(query, pageWhere.args...)
 		if err != nil {
 			jsonError(w, "Database error", 500)
 			return
@@ -1419,22 +1611,50 @@ func handleAPITitles(w http.ResponseWriter, r *http.Request) {
 		defer rows.Close()
 
 		var titles []TitleSearchResult
+		var nextCursor string
+		var lastKeys []any
+		keyPtrs, keyVals := keyDests(len(keyset.keys))
 		for rows.Next() {
 			var t TitleSearchResult
-			rows.Scan(&t.TitleID, &t.Type, &t.DisplayName, &t.StartYear, &t.EndYear, &t.IMDbID, &t.ImageURL, &t.TMDBID, &t.MovieID, &t.ShowID,
+			rows.Scan(append([]any{&t.TitleID, &t.Type, &t.DisplayName, &t.StartYear, &t.EndYear, &t.IMDbID, &t.ImageURL, &t.TMDBID, &t.MovieID, &t.ShowID,
 				&t.NumVotes, &t.AverageRating, &t.OriginalTitle, &t.OriginalLanguage,
-				&t.ReleaseDate, &t.CreatedAt, &t.UpdatedAt)
+				&t.ReleaseDate, &t.CreatedAt, &t.UpdatedAt, &t.PosterPath, &t.ImageBlurhash, &t.ImageColor}, keyPtrs...)...)
+			t.applyImages(imageSize)
+			if len(titles) == perPage {
+				last := titles[len(titles)-1]
+				nextCursor = encodeCursor(sortBy, lastKeys, last.TitleID)
+				break
+			}
 			titles = append(titles, t)
+			lastKeys = append(lastKeys[:0], keyVals...)
 		}
-		totalPages := (total + perPage - 1) / perPage
-		jsonResponse(w, map[string]any{
+		resp := map[string]any{
 			"titles":      titles,
-			"total":       total,
-			"page":        page,
 			"per_page":    perPage,
-			"total_pages": totalPages,
-			"languages":   languages,
-		})
+			"sort":        sortBy,
+			"next_cursor": nil,
+		}
+		if nextCursor != "" {
+			resp["next_cursor"] = nextCursor
+		}
+		if cursor == nil {
+			resp["page"] = page
+		}
+		if withCount {
+			resp["total"] = total
+			resp["total_pages"] = (total + perPage - 1) / perPage
+			resp["languages"] = languages
+		}
+		if f.hasFilters() {
+			resp["query"] = f
+		}
+		if len(facetNames) > 0 {
+			resp["facets"] = computeFacets(facetNames, f, "")
+		}
+		if len(titles) == 0 && cursor == nil && page == 1 && f.Text != "" {
+			resp["suggestions"] = fetchTitleSuggestions(f.Text, f.Type, 5)
+		}
+		jsonResponse(w, resp)
 
 	case "POST":
 		var t Title
@@ -1474,19 +1694,35 @@ func handleAPITitle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/titles/")
+	idStr, sub, _ := strings.Cut(idStr, "/")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		jsonError(w, "Invalid ID", 400)
 		return
 	}
 
+	// /api/titles/:id/enrichment
+	if sub == "enrichment" {
+		handleTitleEnrichment(w, r, id)
+		return
+	} else if sub != "" {
+		jsonError(w, "Not found", 404)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 		t, err := getTitleByID(id)
 		if err != nil {
 			jsonError(w, "Not found", 404)
 			return
 		}
+		applyRegion(&t, strings.ToUpper(r.URL.Query().Get("region")))
+		t.applyImages(imageSize)
 		go logEngagement(t.TitleID, r.URL.Query().Get("source"))
 		jsonResponse(w, t)
 
@@ -1582,12 +1818,19 @@ func handleAPIMovie(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 		movie, err := getMovieByID(id)
 		if err != nil {
 			jsonError(w, "Not found", 404)
 			return
 		}
-		maybeFetchImage(&movie.Title)
+		enrichTitle(&movie.Title, priorityView)
+		movie.Credits, movie.CreditsTotal = loadCreditsForTitle(movie.TitleID, creditLimit(r))
+		applyRegion(&movie.Title, strings.ToUpper(r.URL.Query().Get("region")))
+		movie.Title.applyImages(imageSize)
 		go logEngagement(movie.Title.TitleID, r.URL.Query().Get("source"))
 		jsonResponse(w, movie)
 
@@ -1700,15 +1943,31 @@ func handleAPIShow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle /api/shows/:id/episodes
+	if len(parts) >= 2 && parts[1] == "episodes" {
+		handleShowEpisodes(w, r, id)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 		show, err := getShowByID(id, true)
 		if err != nil {
 			jsonError(w, "Not found", 404)
 			return
 		}
-		maybeFetchImage(&show.Title)
-		maybeFetchEpisodes(&show)
+		enrichShow(&show, priorityView)
+		if order := r.URL.Query().Get("order"); order != "" && !applyEpisodeOrdering(&show, order) {
+			jsonError(w, "No "+order+" ordering for this show", 404)
+			return
+		}
+		show.Credits, show.CreditsTotal = loadCreditsForTitle(show.TitleID, creditLimit(r))
+		applyRegion(&show.Title, strings.ToUpper(r.URL.Query().Get("region")))
+		show.applyImages(imageSize)
 		go logEngagement(show.Title.TitleID, r.URL.Query().Get("source"))
 		jsonResponse(w, show)
 
@@ -1761,7 +2020,11 @@ func handleShowSeasons(w http.ResponseWriter, r *http.Request, showID int) {
 	}
 	switch r.Method {
 	case "GET":
-		rows, err := db.Query(`SELECT id, show_id, season FROM show_seasons WHERE show_id = $1 ORDER BY season`, showID)
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
+		rows, err := db.Query(`SELECT `+seasonColumns+` FROM show_seasons WHERE show_id = $1 ORDER BY season`, showID)
 		if err != nil {
 			jsonError(w, "Database error", 500)
 			return
@@ -1771,7 +2034,8 @@ func handleShowSeasons(w http.ResponseWriter, r *http.Request, showID int) {
 		var seasons []Season
 		for rows.Next() {
 			var s Season
-			rows.Scan(&s.SeasonID, &s.ShowID, &s.SeasonNumber)
+			rows.Scan(s.dests()...)
+			s.applyImages(imageSize)
 			seasons = append(seasons, s)
 		}
 		jsonResponse(w, seasons)
@@ -1825,21 +2089,26 @@ func handleAPISeason(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 		var s Season
-		err := db.QueryRow(`SELECT id, show_id, season FROM show_seasons WHERE id = $1`, id).Scan(&s.SeasonID, &s.ShowID, &s.SeasonNumber)
+		err := db.QueryRow(`SELECT `+seasonColumns+` FROM show_seasons WHERE id = $1`, id).Scan(s.dests()...)
 		if err != nil {
 			jsonError(w, "Not found", 404)
 			return
 		}
 
 		// Get episodes
-		rows, _ := db.Query(`SELECT id, season_id, episode, display_name, image_url, TO_CHAR(air_date, 'YYYY-MM-DD'), runtime_minutes, synopsis FROM show_episodes WHERE season_id = $1 ORDER BY episode`, id)
+		rows, _ := db.Query(`SELECT `+episodeColumns+` FROM show_episodes WHERE season_id = $1 ORDER BY episode`, id)
 		defer rows.Close()
 		for rows.Next() {
 			var e Episode
-			rows.Scan(&e.EpisodeID, &e.SeasonID, &e.EpisodeNumber, &e.DisplayName, &e.ImageURL, &e.AirDate, &e.RuntimeMinutes, &e.Synopsis)
+			rows.Scan(e.dests()...)
 			s.Episodes = append(s.Episodes, e)
 		}
+		s.applyImages(imageSize)
 		jsonResponse(w, s)
 
 	case "DELETE":
@@ -1861,7 +2130,11 @@ func handleSeasonEpisodes(w http.ResponseWriter, r *http.Request, seasonID int)
 	}
 	switch r.Method {
 	case "GET":
-		rows, err := db.Query(`SELECT id, season_id, episode, display_name, image_url, TO_CHAR(air_date, 'YYYY-MM-DD'), runtime_minutes, synopsis FROM show_episodes WHERE season_id = $1 ORDER BY episode`, seasonID)
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
+		rows, err := db.Query(`SELECT `+episodeColumns+` FROM show_episodes WHERE season_id = $1 ORDER BY episode`, seasonID)
 		if err != nil {
 			jsonError(w, "Database error", 500)
 			return
@@ -1871,7 +2144,8 @@ func handleSeasonEpisodes(w http.ResponseWriter, r *http.Request, seasonID int)
 		var episodes []Episode
 		for rows.Next() {
 			var e Episode
-			rows.Scan(&e.EpisodeID, &e.SeasonID, &e.EpisodeNumber, &e.DisplayName, &e.ImageURL, &e.AirDate, &e.RuntimeMinutes, &e.Synopsis)
+			rows.Scan(e.dests()...)
+			e.applyImages(imageSize)
 			episodes = append(episodes, e)
 		}
 		jsonResponse(w, episodes)
@@ -1910,6 +2184,29 @@ func handleAPIEpisode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/episodes/")
+
+	// /api/episodes/by-imdb/:tconst. If IMDb renumbered an episode the old
+	// row keeps its tconst until deleted, so the newest row wins.
+	if tconst, ok := strings.CutPrefix(idStr, "by-imdb/"); ok {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
+		var e Episode
+		err := db.QueryRow(`SELECT `+episodeColumns+` FROM show_episodes WHERE imdb_id = $1 ORDER BY id DESC LIMIT 1`, tconst).Scan(e.dests()...)
+		if err != nil {
+			jsonError(w, "Not found", 404)
+			return
+		}
+		e.applyImages(imageSize)
+		jsonResponse(w, e)
+		return
+	}
+
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		jsonError(w, "Invalid ID", 400)
@@ -1918,12 +2215,17 @@ func handleAPIEpisode(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
+		imageSize, ok := imageSizeParam(w, r)
+		if !ok {
+			return
+		}
 		var e Episode
-		err := db.QueryRow(`SELECT id, season_id, episode, display_name, image_url, TO_CHAR(air_date, 'YYYY-MM-DD'), runtime_minutes, synopsis FROM show_episodes WHERE id = $1`, id).Scan(&e.EpisodeID, &e.SeasonID, &e.EpisodeNumber, &e.DisplayName, &e.ImageURL, &e.AirDate, &e.RuntimeMinutes, &e.Synopsis)
+		err := db.QueryRow(`SELECT `+episodeColumns+` FROM show_episodes WHERE id = $1`, id).Scan(e.dests()...)
 		if err != nil {
 			jsonError(w, "Not found", 404)
 			return
 		}
+		e.applyImages(imageSize)
 		jsonResponse(w, e)
 
 	case "PUT":
@@ -1965,14 +2267,16 @@ func getTitleByID(id int) (Title, error) {
 		SELECT id, type, display_name, start_year, end_year, imdb_id, image_url, tmdb_id,
 		       num_votes, average_rating, original_title, original_language,
 		       TO_CHAR(release_date, 'YYYY-MM-DD'), tmdb_popularity, runtime_minutes,
-		       origin_country, COALESCE(needs_backfill_tmdb, true), created_at, updated_at
-		FROM titles WHERE id = $1
-	`, id).Scan(&t.TitleID, &t.Type, &t.DisplayName, &t.StartYear, &t.EndYear, &t.IMDbID, &t.ImageURL, &t.TMDBID,
+		       origin_country, COALESCE(needs_backfill_tmdb, true), created_at, updated_at,
+		       `+titleImageColumns+`
+		FROM titles t WHERE id = $1
+	`, id).Scan(append([]any{&t.TitleID, &t.Type, &t.DisplayName, &t.StartYear, &t.EndYear, &t.IMDbID, &t.ImageURL, &t.TMDBID,
 		&t.NumVotes, &t.AverageRating, &t.OriginalTitle, &t.OriginalLanguage,
 		&t.ReleaseDate, &t.TMDBPopularity, &t.RuntimeMinutes,
-		&t.OriginCountry, &t.NeedsBackfillTMDB, &t.CreatedAt, &t.UpdatedAt)
+		&t.OriginCountry, &t.NeedsBackfillTMDB, &t.CreatedAt, &t.UpdatedAt}, t.imageDests()...)...)
 	if err == nil {
 		t.Genres = loadGenresForTitle(id)
+		t.Akas = loadAkasForTitle(id)
 	}
 	return t, err
 }
@@ -1983,14 +2287,16 @@ func getMovieByID(id int) (Movie, error) {
 		SELECT m.id, m.title_id, t.id, t.type, t.display_name, t.start_year, t.end_year, t.imdb_id, t.image_url, t.tmdb_id,
 		       t.num_votes, t.average_rating, t.original_title, t.original_language,
 		       TO_CHAR(t.release_date, 'YYYY-MM-DD'), t.tmdb_popularity, t.runtime_minutes,
-		       t.origin_country, COALESCE(t.needs_backfill_tmdb, true), t.created_at, t.updated_at
+		       t.origin_country, COALESCE(t.needs_backfill_tmdb, true), t.created_at, t.updated_at,
+		       `+titleImageColumns+`
 		FROM movies m JOIN titles t ON m.title_id = t.id WHERE m.id = $1
-	`, id).Scan(&m.MovieID, &m.TitleID, &m.Title.TitleID, &m.Title.Type, &m.Title.DisplayName, &m.Title.StartYear, &m.Title.EndYear, &m.Title.IMDbID, &m.Title.ImageURL, &m.Title.TMDBID,
+	`, id).Scan(append([]any{&m.MovieID, &m.TitleID, &m.Title.TitleID, &m.Title.Type, &m.Title.DisplayName, &m.Title.StartYear, &m.Title.EndYear, &m.Title.IMDbID, &m.Title.ImageURL, &m.Title.TMDBID,
 		&m.Title.NumVotes, &m.Title.AverageRating, &m.Title.OriginalTitle, &m.Title.OriginalLanguage,
 		&m.Title.ReleaseDate, &m.Title.TMDBPopularity, &m.Title.RuntimeMinutes,
-		&m.Title.OriginCountry, &m.Title.NeedsBackfillTMDB, &m.Title.CreatedAt, &m.Title.UpdatedAt)
+		&m.Title.OriginCountry, &m.Title.NeedsBackfillTMDB, &m.Title.CreatedAt, &m.Title.UpdatedAt}, m.Title.imageDests()...)...)
 	if err == nil {
 		m.Title.Genres = loadGenresForTitle(m.Title.TitleID)
+		m.Title.Akas = loadAkasForTitle(m.Title.TitleID)
 	}
 	return m, err
 }
@@ -2002,30 +2308,31 @@ func getShowByID(id int, withSeasons bool) (Show, error) {
 		       t.num_votes, t.average_rating, t.original_title, t.original_language,
 		       TO_CHAR(t.release_date, 'YYYY-MM-DD'), t.tmdb_popularity, t.runtime_minutes,
 		       t.origin_country, COALESCE(t.needs_backfill_tmdb, true), t.created_at, t.updated_at,
-		       t.episodes_checked_at
+		       t.episodes_checked_at, `+showStatusColumns+`, `+titleImageColumns+`
 		FROM shows s JOIN titles t ON s.title_id = t.id WHERE s.id = $1
-	`, id).Scan(&s.ShowID, &s.TitleID, &s.Title.TitleID, &s.Title.Type, &s.Title.DisplayName, &s.Title.StartYear, &s.Title.EndYear, &s.Title.IMDbID, &s.Title.ImageURL, &s.Title.TMDBID,
+	`, id).Scan(append([]any{&s.ShowID, &s.TitleID, &s.Title.TitleID, &s.Title.Type, &s.Title.DisplayName, &s.Title.StartYear, &s.Title.EndYear, &s.Title.IMDbID, &s.Title.ImageURL, &s.Title.TMDBID,
 		&s.Title.NumVotes, &s.Title.AverageRating, &s.Title.OriginalTitle, &s.Title.OriginalLanguage,
 		&s.Title.ReleaseDate, &s.Title.TMDBPopularity, &s.Title.RuntimeMinutes,
 		&s.Title.OriginCountry, &s.Title.NeedsBackfillTMDB, &s.Title.CreatedAt, &s.Title.UpdatedAt,
-		&s.Title.EpisodesCheckedAt)
+		&s.Title.EpisodesCheckedAt}, append(s.statusDests(), s.Title.imageDests()...)...)...)
 	if err != nil {
 		return s, err
 	}
 	s.Title.Genres = loadGenresForTitle(s.Title.TitleID)
+	s.Title.Akas = loadAkasForTitle(s.Title.TitleID)
 
 	if withSeasons {
-		rows, _ := db.Query(`SELECT id, show_id, season FROM show_seasons WHERE show_id = $1 ORDER BY season`, id)
+		rows, _ := db.Query(`SELECT `+seasonColumns+` FROM show_seasons WHERE show_id = $1 ORDER BY season`, id)
 		defer rows.Close()
 		for rows.Next() {
 			var sn Season
-			rows.Scan(&sn.SeasonID, &sn.ShowID, &sn.SeasonNumber)
+			rows.Scan(sn.dests()...)
 
 			// Get episodes for this season
-			epRows, _ := db.Query(`SELECT id, season_id, episode, display_name, image_url, TO_CHAR(air_date, 'YYYY-MM-DD'), runtime_minutes, synopsis FROM show_episodes WHERE season_id = $1 ORDER BY episode`, sn.SeasonID)
+			epRows, _ := db.Query(`SELECT `+episodeColumns+` FROM show_episodes WHERE season_id = $1 ORDER BY episode`, sn.SeasonID)
 			for epRows.Next() {
 				var e Episode
-				epRows.Scan(&e.EpisodeID, &e.SeasonID, &e.EpisodeNumber, &e.DisplayName, &e.ImageURL, &e.AirDate, &e.RuntimeMinutes, &e.Synopsis)
+				epRows.Scan(e.dests()...)
 				sn.Episodes = append(sn.Episodes, e)
 			}
 			epRows.Close()
@@ -2033,25 +2340,8 @@ func getShowByID(id int, withSeasons bool) (Show, error) {
 			s.Seasons = append(s.Seasons, sn)
 		}
 
-		// Derive is_series_finished and is_season_finished flags
-		finished := s.Title.EndYear != nil
-		s.IsSeriesFinished = &finished
-
-		maxSeason := 0
-		for _, sn := range s.Seasons {
-			if sn.SeasonNumber > maxSeason {
-				maxSeason = sn.SeasonNumber
-			}
-		}
-		for i := range s.Seasons {
-			var sf bool
-			if s.Title.EndYear != nil {
-				sf = true
-			} else {
-				sf = s.Seasons[i].SeasonNumber < maxSeason
-			}
-			s.Seasons[i].IsSeasonFinished = &sf
-		}
+		deriveFinishedFlags(&s)
+		s.Orderings = loadShowOrderings(id)
 	}
 
 	return s, nil
@@ -2074,6 +2364,69 @@ func loadGenresForTitle(titleID int) []string {
 	return genres
 }
 
+func loadAkasForTitle(titleID int) []TitleAka {
+	rows, err := db.Query(`SELECT title, region, language, COALESCE(types, ''), is_original FROM title_akas WHERE title_id = $1 ORDER BY ordering`, titleID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var akas []TitleAka
+	for rows.Next() {
+		var a TitleAka
+		var types string
+		rows.Scan(&a.Title, &a.Region, &a.Language, &types, &a.IsOriginal)
+		if types != "" {
+			a.Types = strings.Split(types, ",")
+		}
+		akas = append(akas, a)
+	}
+	return akas
+}
+
+// regionalName picks the title shown to users in region (ISO 3166-1, e.g. "ES").
+// IMDb marks the preferred local title with type "imdbDisplay"; otherwise the
+// first untyped aka wins, then any aka except working titles. Returns "" if the
+// title has no aka for that region.
+func regionalName(akas []TitleAka, region string) string {
+	best, bestRank := "", 0
+	for _, a := range akas {
+		if a.Region == nil || !strings.EqualFold(*a.Region, region) {
+			continue
+		}
+		rank := 1
+		switch {
+		case slices.Contains(a.Types, "imdbDisplay"):
+			rank = 4
+		case len(a.Types) == 0:
+			rank = 3
+		case !slices.Contains(a.Types, "working"):
+			rank = 2
+		}
+		if rank > bestRank {
+			best, bestRank = a.Title, rank
+		}
+	}
+	return best
+}
+
+// regionalNameExpr is the SQL counterpart of regionalName for list queries:
+// the preferred aka for region $argNum, falling back to display_name.
+func regionalNameExpr(argNum int) string {
+	return fmt.Sprintf(`COALESCE((SELECT a.title FROM title_akas a WHERE a.title_id = t.id AND a.region = $%d
+		ORDER BY CASE WHEN a.types LIKE '%%imdbDisplay%%' THEN 0 WHEN a.types IS NULL THEN 1 WHEN a.types NOT LIKE '%%working%%' THEN 2 ELSE 3 END, a.ordering
+		LIMIT 1), t.display_name)`, argNum)
+}
+
+// applyRegion swaps a title's display_name for its regional name, if it has one.
+func applyRegion(t *Title, region string) {
+	if region == "" {
+		return
+	}
+	if name := regionalName(t.Akas, region); name != "" {
+		t.DisplayName = name
+	}
+}
+
 func loadGenresForTitles(titleIDs []int) map[int][]string {
 	if len(titleIDs) == 0 {
 		return nil
@@ -2245,7 +2598,6 @@ func buildCarouselCache() {
 		JOIN title_genres tg ON tg.title_id = t.id
 		JOIN genres g ON tg.genre_id = g.id
 		WHERE t.image_url IS NOT NULL
-			AND t.image_url NOT IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY')
 			AND t.num_votes >= 5000
 			AND t.average_rating IS NOT NULL
 		GROUP BY t.type, g.name
@@ -2263,7 +2615,7 @@ func buildCarouselCache() {
 	// Get top 30 per type+genre
 	rows, err := db.Query(`
 		WITH ranked AS (
-			SELECT t.id, t.type, t.display_name, t.start_year, t.image_url,
+			SELECT t.id, t.type, t.display_name, t.start_year, t.image_url, t.image_blurhash, t.image_color,
 				m.id as movie_id, s.id as show_id,
 				t.average_rating, t.num_votes, t.tmdb_popularity,
 				g.name as genre,
@@ -2278,11 +2630,10 @@ func buildCarouselCache() {
 			JOIN title_genres tg ON tg.title_id = t.id
 			JOIN genres g ON tg.genre_id = g.id
 			WHERE t.image_url IS NOT NULL
-				AND t.image_url NOT IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY')
 				AND t.num_votes >= 5000
 				AND t.average_rating IS NOT NULL
 		)
-		SELECT id, type, display_name, start_year, image_url, movie_id, show_id,
+		SELECT id, type, display_name, start_year, image_url, image_blurhash, image_color, movie_id, show_id,
 			average_rating, num_votes, tmdb_popularity, genre, engagement_count
 		FROM ranked
 		WHERE rn <= 30
@@ -2304,7 +2655,7 @@ func buildCarouselCache() {
 	for rows.Next() {
 		var d DiscoverTitle
 		var genre string
-		rows.Scan(&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL,
+		rows.Scan(&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL, &d.ImageBlurhash, &d.ImageColor,
 			&d.MovieID, &d.ShowID, &d.AverageRating, &d.NumVotes, &d.TMDBPopularity, &genre, &d.EngagementCount)
 		entries = append(entries, entry{d, d.Type + ":" + genre})
 		uniqueIDs[d.TitleID] = true
@@ -2328,6 +2679,11 @@ func buildCarouselCache() {
 	for key, titles := range titlesByKey {
 		cache[key] = carouselBucket{Titles: titles, TotalCount: counts[key]}
 	}
+	var all []DiscoverTitle
+	for _, e := range entries {
+		all = append(all, e.title)
+	}
+	queuePlaceholders(all)
 
 	carouselCacheMu.Lock()
 	carouselCache = cache
@@ -2339,107 +2695,83 @@ func buildCarouselCache() {
 
 // Discover page helpers
 
-func fetchDiscoverTitles(sortBy, typeFilter, langFilter, genreFilter, countryFilter, yearMin, ratingMin, minVotes string, limit, offset int) ([]DiscoverTitle, int) {
-	where := `WHERE t.image_url IS NOT NULL AND t.image_url NOT IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY')`
-	var args []any
-	argNum := 1
-
-	if typeFilter != "" {
-		where += fmt.Sprintf(` AND t.type = $%d`, argNum)
-		args = append(args, typeFilter)
-		argNum++
-	}
-	if langFilter != "" {
-		where += fmt.Sprintf(` AND t.original_language = $%d`, argNum)
-		args = append(args, langFilter)
-		argNum++
-	}
-	if genreFilter != "" {
-		where += fmt.Sprintf(` AND EXISTS(SELECT 1 FROM title_genres tg JOIN genres g ON tg.genre_id=g.id WHERE tg.title_id=t.id AND g.name=$%d)`, argNum)
-		args = append(args, genreFilter)
-		argNum++
-	}
-	if countryFilter != "" {
-		where += fmt.Sprintf(` AND t.origin_country = $%d`, argNum)
-		args = append(args, countryFilter)
-		argNum++
-	}
-	if yearMin != "" {
-		if y, err := strconv.Atoi(yearMin); err == nil {
-			where += fmt.Sprintf(` AND t.start_year >= $%d`, argNum)
-			args = append(args, y)
-			argNum++
-		}
-	}
-	if ratingMin != "" {
-		if r, err := strconv.ParseFloat(ratingMin, 64); err == nil {
-			where += fmt.Sprintf(` AND t.average_rating >= $%d`, argNum)
-			args = append(args, r)
-			argNum++
-		}
-	}
-	if minVotes != "" {
-		if v, err := strconv.Atoi(minVotes); err == nil {
-			where += fmt.Sprintf(` AND t.num_votes >= $%d`, argNum)
-			args = append(args, v)
-			argNum++
-		}
-	}
-
-	orderBy := "t.num_votes DESC NULLS LAST"
+// discoverBaseWhere is the condition every discover listing starts from:
+// titles with a poster, plus the implicit thresholds of some sort modes.
+func discoverBaseWhere(sortBy string, f titleFilter) string {
+	cond := `t.image_url IS NOT NULL`
 	switch sortBy {
-	case "most_rated":
-		orderBy = "t.num_votes DESC NULLS LAST"
-	case "trending":
-		orderBy = "t.tmdb_popularity DESC NULLS LAST"
-	case "popular":
-		orderBy = "(SELECT COUNT(*) FROM title_views tv WHERE tv.title_id = t.id) DESC"
 	case "top_rated":
-		orderBy = "t.average_rating DESC NULLS LAST"
-		if minVotes == "" && ratingMin == "" {
-			where += ` AND t.num_votes >= 1000`
+		if f.Votes == nil && f.Rating == nil {
+			cond += ` AND t.num_votes >= 1000`
 		}
-	case "newest":
-		orderBy = "t.start_year DESC NULLS LAST, t.release_date DESC NULLS LAST"
 	case "hidden_gems":
-		orderBy = "t.average_rating DESC NULLS LAST"
-		where += ` AND t.average_rating >= 7.5 AND t.num_votes < 10000 AND t.num_votes > 100`
-	case "a-z":
-		orderBy = "t.display_name ASC"
+		cond += ` AND t.average_rating >= 7.5 AND t.num_votes < 10000 AND t.num_votes > 100`
 	}
+	return cond
+}
+
+// discoverPage selects one page of a discover listing: by Offset, or after
+// Cursor when it is set. The total is only counted when Count is true.
+type discoverPage struct {
+	Limit, Offset int
+	Cursor        *pageCursor
+	Count         bool
+}
+
+// fetchDiscoverTitles returns a page of titles, the total (-1 if not
+// counted) and the cursor for the next page ("" on the last page).
+func fetchDiscoverTitles(sortBy string, f titleFilter, pg discoverPage) ([]DiscoverTitle, int, string) {
+	w := newSQLWhere(discoverBaseWhere(sortBy, f))
+	f.apply(w)
+	keyset := titleKeyset(sortBy, "")
 
 	// Get total count
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM titles t %s`, where)
-	var total int
-	db.QueryRow(countQuery, args...).Scan(&total)
+	total := -1
+	if pg.Count {
+		db.QueryRow(`SELECT COUNT(*) FROM titles t`+w.String(), w.args...).Scan(&total)
+	}
+
+	offset := pg.Offset
+	if pg.Cursor != nil {
+		keyset.after(w, pg.Cursor)
+		offset = 0
+	}
 
+	// One extra row tells us whether there is a next page
 	query := fmt.Sprintf(`
-		SELECT t.id, t.type, t.display_name, t.start_year, t.image_url,
+		SELECT t.id, t.type, t.display_name, t.start_year, t.image_url, t.image_blurhash, t.image_color,
 		       m.id, s.id, t.average_rating, t.num_votes, t.tmdb_popularity,
-		       COALESCE((SELECT COUNT(*) FROM title_views tv WHERE tv.title_id = t.id), 0)
+		       COALESCE((SELECT COUNT(*) FROM title_views tv WHERE tv.title_id = t.id), 0)%s
 		FROM titles t
 		LEFT JOIN movies m ON m.title_id = t.id
-		LEFT JOIN shows s ON s.title_id = t.id
-		%s
+		LEFT JOIN shows s ON s.title_id = t.id%s
 		ORDER BY %s
 		LIMIT %d OFFSET %d
-	`, where, orderBy, limit, offset)
+	`, keyset.selectKeys(), w.String(), keyset.orderBy(), pg.Limit+1, offset)
 
-	rows, err := db.Query(query, args...)
+	rows, err := db.Query(query, w.args...)
 	if err != nil {
 		log.Printf("fetchDiscoverTitles error: %v", err)
-		return nil, total
+		return nil, total, ""
 	}
 	defer rows.Close()
 
 	var titles []DiscoverTitle
 	var titleIDs []int
+	var nextCursor string
+	var lastKeys []any
+	keyPtrs, keyVals := keyDests(len(keyset.keys))
 	for rows.Next() {
 		var d DiscoverTitle
-		rows.Scan(&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL,
-			&d.MovieID, &d.ShowID, &d.AverageRating, &d.NumVotes, &d.TMDBPopularity, &d.EngagementCount)
+		rows.Scan(append([]any{&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL, &d.ImageBlurhash, &d.ImageColor,
+			&d.MovieID, &d.ShowID, &d.AverageRating, &d.NumVotes, &d.TMDBPopularity, &d.EngagementCount}, keyPtrs...)...)
+		if len(titles) == pg.Limit {
+			nextCursor = encodeCursor(sortBy, lastKeys, titles[len(titles)-1].TitleID)
+			break
+		}
 		titles = append(titles, d)
 		titleIDs = append(titleIDs, d.TitleID)
+		lastKeys = append(lastKeys[:0], keyVals...)
 	}
 
 	// Load genres for all titles
@@ -2447,8 +2779,9 @@ func fetchDiscoverTitles(sortBy, typeFilter, langFilter, genreFilter, countryFil
 	for i := range titles {
 		titles[i].Genres = genreMap[titles[i].TitleID]
 	}
+	queuePlaceholders(titles)
 
-	return titles, total
+	return titles, total, nextCursor
 }
 
 func getCollectionTitles(collID int, strategy string, filterParamsJSON []byte) []DiscoverTitle {
@@ -2473,7 +2806,7 @@ func getCollectionTitles(collID int, strategy string, filterParamsJSON []byte) [
 		if fp.MinVotes > 0 {
 			minVotes = strconv.Itoa(fp.MinVotes)
 		}
-		titles, _ := fetchDiscoverTitles(fp.Sort, fp.Type, fp.Lang, fp.Genre, "", "", "", minVotes, fp.Limit, 0)
+		titles, _, _ := fetchDiscoverTitles(fp.Sort, discoverFilter(fp.Type, fp.Lang, fp.Genre, "", "", "", minVotes), discoverPage{Limit: fp.Limit})
 		return titles
 	case "static", "llm":
 		return fetchStaticCollectionTitles(collID)
@@ -2484,7 +2817,7 @@ func getCollectionTitles(collID int, strategy string, filterParamsJSON []byte) [
 
 func fetchStaticCollectionTitles(collID int) []DiscoverTitle {
 	rows, err := db.Query(`
-		SELECT t.id, t.type, t.display_name, t.start_year, t.image_url,
+		SELECT t.id, t.type, t.display_name, t.start_year, t.image_url, t.image_blurhash, t.image_color,
 		       m.id, s.id, t.average_rating, t.num_votes, t.tmdb_popularity,
 		       COALESCE((SELECT COUNT(*) FROM title_views tv WHERE tv.title_id = t.id), 0)
 		FROM collection_titles ct
@@ -2504,7 +2837,7 @@ func fetchStaticCollectionTitles(collID int) []DiscoverTitle {
 	var titleIDs []int
 	for rows.Next() {
 		var d DiscoverTitle
-		rows.Scan(&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL,
+		rows.Scan(&d.TitleID, &d.Type, &d.DisplayName, &d.StartYear, &d.ImageURL, &d.ImageBlurhash, &d.ImageColor,
 			&d.MovieID, &d.ShowID, &d.AverageRating, &d.NumVotes, &d.TMDBPopularity, &d.EngagementCount)
 		titles = append(titles, d)
 		titleIDs = append(titleIDs, d.TitleID)
@@ -2514,6 +2847,7 @@ func fetchStaticCollectionTitles(collID int) []DiscoverTitle {
 	for i := range titles {
 		titles[i].Genres = genreMap[titles[i].TitleID]
 	}
+	queuePlaceholders(titles)
 
 	return titles
 }
@@ -2556,7 +2890,7 @@ func handleDiscoverPage(w http.ResponseWriter, r *http.Request) {
 	var genreChips []chipItem
 	var countryChips []chipItem
 
-	gRows, _ := db.Query(`SELECT g.name, COUNT(*) as cnt FROM genres g JOIN title_genres tg ON tg.genre_id = g.id JOIN titles t ON tg.title_id = t.id WHERE t.image_url IS NOT NULL AND t.image_url NOT IN ('none','TMDB_NOT_FOUND_DO_NOT_RETRY') GROUP BY g.name ORDER BY cnt DESC LIMIT 15`)
+	gRows, _ := db.Query(`SELECT g.name, COUNT(*) as cnt FROM genres g JOIN title_genres tg ON tg.genre_id = g.id JOIN titles t ON tg.title_id = t.id WHERE t.image_url IS NOT NULL GROUP BY g.name ORDER BY cnt DESC LIMIT 15`)
 	if gRows != nil {
 		defer gRows.Close()
 		for gRows.Next() {
@@ -2566,7 +2900,7 @@ func handleDiscoverPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	cRows, _ := db.Query(`SELECT origin_country, COUNT(*) as cnt FROM titles WHERE origin_country IS NOT NULL AND origin_country != '' AND image_url IS NOT NULL AND image_url NOT IN ('none','TMDB_NOT_FOUND_DO_NOT_RETRY') GROUP BY origin_country ORDER BY cnt DESC LIMIT 15`)
+	cRows, _ := db.Query(`SELECT origin_country, COUNT(*) as cnt FROM titles WHERE origin_country IS NOT NULL AND origin_country != '' AND image_url IS NOT NULL GROUP BY origin_country ORDER BY cnt DESC LIMIT 15`)
 	if cRows != nil {
 		defer cRows.Close()
 		for cRows.Next() {
@@ -2589,7 +2923,7 @@ func handleDiscoverPage(w http.ResponseWriter, r *http.Request) {
 			go logCollectionClick(c.ID)
 		}
 	} else if hasFilters {
-		filteredTitles, filteredTotal = fetchDiscoverTitles(sortBy, typeFilter, langFilter, genre, countryFilter, yearMin, ratingMin, "", 100, 0)
+		filteredTitles, filteredTotal, _ = fetchDiscoverTitles(sortBy, discoverFilter(typeFilter, langFilter, genre, countryFilter, yearMin, ratingMin, ""), discoverPage{Limit: 100, Count: true})
 	} else {
 		// Default: alternating carousels from cache
 		carouselCacheMu.RLock()
@@ -2697,11 +3031,48 @@ func handleAPIDiscover(w http.ResponseWriter, r *http.Request) {
 	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 1 {
 		page = p
 	}
-	offset := (page - 1) * limit
+	pg := discoverPage{Limit: limit, Offset: (page - 1) * limit, Count: countParam(r.URL.Query().Get("count"))}
 
 	minVotes := r.URL.Query().Get("min_votes")
-	titles, total := fetchDiscoverTitles(sortBy, typeFilter, langFilter, genre, countryFilter, yearMin, ratingMin, minVotes, limit, offset)
-	jsonResponse(w, map[string]any{"titles": titles, "total": total, "page": page, "per_page": limit})
+	f := discoverFilter(typeFilter, langFilter, genre, countryFilter, yearMin, ratingMin, minVotes)
+
+	// q takes the same query syntax as /api/titles; explicit params win
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		parsed, qerr := parseTitleQuery(q)
+		if qerr != nil {
+			queryErrorResponse(w, qerr)
+			return
+		}
+		f = mergeTitleFilters(parsed, f)
+	}
+	facetNames, err := parseFacets(r.URL.Query().Get("facets"))
+	if err != nil {
+		jsonError(w, err.Error(), 400)
+		return
+	}
+
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		if pg.Cursor, err = decodeCursor(token, sortBy, titleKeyset(sortBy, "")); err != nil {
+			jsonError(w, err.Error(), 400)
+			return
+		}
+	}
+
+	titles, total, nextCursor := fetchDiscoverTitles(sortBy, f, pg)
+	resp := map[string]any{"titles": proxyDiscoverImages(titles), "per_page": limit, "next_cursor": nil}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	if pg.Cursor == nil {
+		resp["page"] = page
+	}
+	if pg.Count {
+		resp["total"] = total
+	}
+	if len(facetNames) > 0 {
+		resp["facets"] = computeFacets(facetNames, f, discoverBaseWhere(sortBy, f))
+	}
+	jsonResponse(w, resp)
 }
 
 func handleAPIDiscoverCarousels(w http.ResponseWriter, r *http.Request) {
@@ -2775,7 +3146,7 @@ func handleAPIDiscoverCarousels(w http.ResponseWriter, r *http.Request) {
 				all = append(all, carouselResult{
 					Name: cm.Name, Slug: cm.Slug, Description: cm.Description,
 					CollectionID: cm.ID, EngagementCount: cm.EngagementCount,
-					TotalCount: bucket.TotalCount, Titles: bucket.Titles,
+					TotalCount: bucket.TotalCount, Titles: proxyDiscoverImages(bucket.Titles),
 				})
 			}
 		}
@@ -2786,7 +3157,7 @@ func handleAPIDiscoverCarousels(w http.ResponseWriter, r *http.Request) {
 				all = append(all, carouselResult{
 					Name: cm.Name, Slug: cm.Slug, Description: cm.Description,
 					CollectionID: cm.ID, EngagementCount: cm.EngagementCount,
-					TotalCount: bucket.TotalCount, Titles: bucket.Titles,
+					TotalCount: bucket.TotalCount, Titles: proxyDiscoverImages(bucket.Titles),
 				})
 			}
 		}
@@ -2849,7 +3220,7 @@ func handleAPICollection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	titles := getCollectionTitles(c.ID, c.Strategy, filterParams)
+	titles := proxyDiscoverImages(getCollectionTitles(c.ID, c.Strategy, filterParams))
 	go logCollectionClick(c.ID)
 
 	jsonResponse(w, map[string]any{
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"mediacanon.org/backend/internal/orderings"
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// Episode orderings
+//
+// Our own (season, episode) numbering comes from IMDb. episode_orderings maps
+// each episode into other numberings of the same show:
+//
+//   - absolute:   every non-special episode in one season 1, numbered in our
+//     order; maintained by cmd/sync and refreshed by the lazy TMDB fetch
+//   - aired:      TMDB's default numbering, recorded by maybeFetchEpisodes
+//     as it matches our episodes to TMDB's. Usually our own numbering, but
+//     not for shows TMDB lists as one flat season
+//   - dvd:        TMDB's first DVD episode group
+//   - group:<id>: every other TMDB episode group, keyed by TMDB group id
+//
+// Only episodes with a known position have a row, so an ordering can cover
+// part of a show.
+
+// orderingPos is an episode's place in one ordering
+type orderingPos struct {
+	Season  int
+	Episode int
+}
+
+// refreshAbsoluteOrdering renumbers a show's absolute ordering after its
+// episodes changed.
+func refreshAbsoluteOrdering(showID int) error {
+	if _, err := db.Exec(orderings.AbsoluteSQL, showID); err != nil {
+		return err
+	}
+	_, err := db.Exec(orderings.AbsoluteCleanupSQL, showID)
+	return err
+}
+
+// absoluteCurrent reports whether absolute, as loaded by loadEpisodeOrdering,
+// numbers exactly show's non-special episodes in order, i.e. whether the
+// episodes are unchanged since it was last refreshed.
+func absoluteCurrent(show *Show, absolute map[int]orderingPos) bool {
+	n := 0
+	for _, season := range show.Seasons {
+		if season.SeasonNumber <= 0 {
+			continue
+		}
+		for _, ep := range season.Episodes {
+			n++
+			if absolute[ep.EpisodeID] != (orderingPos{1, n}) {
+				return false
+			}
+		}
+	}
+	return n == len(absolute)
+}
+
+// loadEpisodeOrdering returns each episode's position in one ordering, keyed
+// by episode id.
+func loadEpisodeOrdering(showID int, ordering string) map[int]orderingPos {
+	positions := map[int]orderingPos{}
+	rows, err := db.Query(`SELECT episode_id, season, episode FROM episode_orderings WHERE show_id = $1 AND ordering = $2`, showID, ordering)
+	if err != nil {
+		log.Printf("Failed to load %s ordering for show %d: %v", ordering, showID, err)
+		return positions
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var p orderingPos
+		rows.Scan(&id, &p.Season, &p.Episode)
+		positions[id] = p
+	}
+	return positions
+}
+
+// loadShowOrderings lists the orderings a show has positions for.
+func loadShowOrderings(showID int) []string {
+	rows, err := db.Query(`SELECT DISTINCT ordering FROM episode_orderings WHERE show_id = $1 ORDER BY ordering`, showID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var orderings []string
+	for rows.Next() {
+		var o string
+		rows.Scan(&o)
+		orderings = append(orderings, o)
+	}
+	return orderings
+}
+
+// storeEpisodeOrdering upserts positions into one ordering, in batches of
+// orderingBatchSize. With replace, the ordering's other rows for the show
+// are deleted first, in the same transaction.
+func storeEpisodeOrdering(showID int, ordering string, positions map[int]orderingPos, replace bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec(`DELETE FROM episode_orderings WHERE show_id = $1 AND ordering = $2`, showID, ordering); err != nil {
+			return err
+		}
+	}
+	var values []string
+	args := []any{showID, ordering}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		_, err := tx.Exec(`
+			INSERT INTO episode_orderings (episode_id, ordering, show_id, season, episode)
+			SELECT v.id, $2, $1, v.season, v.episode
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, season, episode)
+			ON CONFLICT (episode_id, ordering) DO UPDATE SET season = EXCLUDED.season, episode = EXCLUDED.episode
+		`, args...)
+		values, args = values[:0], args[:2]
+		return err
+	}
+	for id, p := range positions {
+		b := len(args)
+		values = append(values, fmt.Sprintf("($%d::int, $%d::int, $%d::int)", b+1, b+2, b+3))
+		args = append(args, id, p.Season, p.Episode)
+		if len(values) == orderingBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// orderingBatchSize keeps each insert under Postgres's 65535 parameters
+const orderingBatchSize = 5000
+
+// deleteEpisodeOrdering drops episodes' positions in one ordering.
+func deleteEpisodeOrdering(ordering string, episodeIDs []int) error {
+	if len(episodeIDs) == 0 {
+		return nil
+	}
+	_, err := db.Exec(`DELETE FROM episode_orderings WHERE ordering = $1 AND episode_id = ANY($2)`, ordering, pq.Array(episodeIDs))
+	return err
+}
+
+// TMDB episode groups (/tv/{id}/episode_groups)
+
+// episodeGroupsMaxAge is how long a show's stored groups are trusted while
+// TMDB's group list is unchanged
+const episodeGroupsMaxAge = 30 * 24 * time.Hour
+
+// episodeGroupsKey summarizes a group list, so a changed list is noticed
+// without fetching every group.
+func episodeGroupsKey(groups []tmdb.EpisodeGroupSummary) string {
+	parts := make([]string, len(groups))
+	for i, g := range groups {
+		parts[i] = fmt.Sprintf("%s:%d:%d:%d", g.ID, g.Type, g.EpisodeCount, g.GroupCount)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// storeTMDBEpisodeGroups replaces the show's dvd and group:<id> orderings
+// with TMDB's current episode groups. Group episodes are in TMDB's aired
+// numbering, so they are matched to our episodes through aired (episode id
+// → aired position); unmatched ones are skipped. Each group costs a request,
+// so they are only fetched when TMDB's group list changed, airedChanged is
+// set, or they are over episodeGroupsMaxAge old.
+func storeTMDBEpisodeGroups(ctx context.Context, show *Show, tmdbID int, aired map[int]orderingPos, airedChanged bool) {
+	groups, err := tmdbAPI.EpisodeGroups(ctx, tmdbID)
+	if err != nil {
+		log.Printf("TMDB episode groups fetch failed for %s: %v", show.Title.DisplayName, err)
+		return
+	}
+	key := episodeGroupsKey(groups)
+	var storedKey *string
+	var checkedAt *time.Time
+	db.QueryRow(`SELECT episode_groups_key, episode_groups_checked_at FROM shows WHERE id = $1`, show.ShowID).Scan(&storedKey, &checkedAt)
+	if !airedChanged && storedKey != nil && *storedKey == key && checkedAt != nil && time.Since(*checkedAt) < episodeGroupsMaxAge {
+		return
+	}
+
+	byAired := make(map[orderingPos]int, len(aired))
+	for id, p := range aired {
+		byAired[p] = id
+	}
+
+	keep := map[string]bool{}
+	complete := true
+	for _, g := range groups {
+		ordering := "group:" + g.ID
+		if g.Type == tmdb.GroupDVD && !keep["dvd"] {
+			ordering = "dvd"
+		}
+		group, err := tmdbAPI.EpisodeGroup(ctx, g.ID)
+		if err != nil {
+			log.Printf("TMDB episode group %s fetch failed for %s: %v", g.ID, show.Title.DisplayName, err)
+			keep[ordering] = true // keep what we had
+			complete = false
+			continue
+		}
+		positions := map[int]orderingPos{}
+		for _, sub := range group.Groups {
+			for _, ep := range sub.Episodes {
+				if id, ok := byAired[orderingPos{ep.SeasonNumber, ep.EpisodeNumber}]; ok {
+					positions[id] = orderingPos{sub.Order, ep.Order + 1}
+				}
+			}
+		}
+		if err := storeEpisodeOrdering(show.ShowID, ordering, positions, true); err != nil {
+			log.Printf("Failed to store %s ordering for %s: %v", ordering, show.Title.DisplayName, err)
+			complete = false
+		}
+		keep[ordering] = true
+	}
+
+	// Groups TMDB no longer lists
+	existing := loadShowOrderings(show.ShowID)
+	for _, o := range existing {
+		if (o == "dvd" || strings.HasPrefix(o, "group:")) && !keep[o] {
+			db.Exec(`DELETE FROM episode_orderings WHERE show_id = $1 AND ordering = $2`, show.ShowID, o)
+		}
+	}
+	// A partial refresh is retried on the next run
+	if complete {
+		db.Exec(`UPDATE shows SET episode_groups_key = $2, episode_groups_checked_at = NOW() WHERE id = $1`, show.ShowID, key)
+	}
+}
+
+// applyEpisodeOrdering regroups show.Seasons by an alternate ordering. The
+// resulting seasons exist only in that ordering, so they carry no season_id
+// or metadata; episodes keep their ids and real season_id but take the
+// ordering's episode number. Episodes without a position are left out.
+// Returns false if the show has no positions in that ordering.
+func applyEpisodeOrdering(show *Show, ordering string) bool {
+	positions := loadEpisodeOrdering(show.ShowID, ordering)
+	if len(positions) == 0 {
+		return false
+	}
+	bySeason := map[int][]Episode{}
+	for _, sn := range show.Seasons {
+		for _, ep := range sn.Episodes {
+			p, ok := positions[ep.EpisodeID]
+			if !ok {
+				continue
+			}
+			ep.EpisodeNumber = p.Episode
+			bySeason[p.Season] = append(bySeason[p.Season], ep)
+		}
+	}
+	seasons := make([]Season, 0, len(bySeason))
+	for n, eps := range bySeason {
+		sort.Slice(eps, func(i, j int) bool { return eps[i].EpisodeNumber < eps[j].EpisodeNumber })
+		seasons = append(seasons, Season{ShowID: show.ShowID, SeasonNumber: n, Episodes: eps})
+	}
+	sort.Slice(seasons, func(i, j int) bool { return seasons[i].SeasonNumber < seasons[j].SeasonNumber })
+	show.Seasons = seasons
+	show.Order = ordering
+	return true
+}
+
+// handleShowEpisodes resolves one episode by its position in an ordering:
+// /api/shows/:id/episodes?absolute=57 or ?order=dvd&season=2&episode=3.
+func handleShowEpisodes(w http.ResponseWriter, r *http.Request, showID int) {
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+	q := r.URL.Query()
+	ordering := q.Get("order")
+	var season, episode int
+	var err error
+	if abs := q.Get("absolute"); abs != "" {
+		ordering, season = "absolute", 1
+		episode, err = strconv.Atoi(abs)
+	} else {
+		if ordering == "" {
+			jsonError(w, "absolute, or order, season and episode are required", 400)
+			return
+		}
+		season, err = strconv.Atoi(q.Get("season"))
+		if err == nil {
+			episode, err = strconv.Atoi(q.Get("episode"))
+		}
+	}
+	if err != nil {
+		jsonError(w, "Invalid season or episode number", 400)
+		return
+	}
+	imageSize, ok := imageSizeParam(w, r)
+	if !ok {
+		return
+	}
+
+	var e Episode
+	err = db.QueryRow(`
+		SELECT `+episodeColumns+` FROM show_episodes
+		WHERE id = (SELECT episode_id FROM episode_orderings
+		            WHERE show_id = $1 AND ordering = $2 AND season = $3 AND episode = $4 LIMIT 1)
+	`, showID, ordering, season, episode).Scan(e.dests()...)
+	if err != nil {
+		jsonError(w, "Not found", 404)
+		return
+	}
+	e.applyImages(imageSize)
+	jsonResponse(w, e)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// People
+//
+// Bio fields come from IMDb name.basics; filmographies from title_credits
+// (see credits.go). A person's popularity is the total num_votes of the
+// titles they are credited on.
+
+// Person is the /api/people/:id payload
+type Person struct {
+	PersonID          int                `json:"person_id"`
+	IMDbID            string             `json:"imdb_id"`
+	DisplayName       string             `json:"display_name"`
+	BirthYear         *int               `json:"birth_year,omitempty"`
+	DeathYear         *int               `json:"death_year,omitempty"`
+	PrimaryProfession []string           `json:"primary_profession,omitempty"`
+	KnownFor          []PersonCredit     `json:"known_for"`
+	Filmography       []FilmographyGroup `json:"filmography"`
+}
+
+// PersonCredit is a title on a person's filmography
+type PersonCredit struct {
+	TitleID       int      `json:"title_id"`
+	Type          string   `json:"type"`
+	DisplayName   string   `json:"display_name"`
+	StartYear     *int     `json:"start_year,omitempty"`
+	EndYear       *int     `json:"end_year,omitempty"`
+	MovieID       *int     `json:"movie_id,omitempty"`
+	ShowID        *int     `json:"show_id,omitempty"`
+	ImageURL      *string  `json:"image_url,omitempty"`
+	ImageBlurhash *string  `json:"image_blurhash,omitempty"`
+	ImageColor    *string  `json:"image_color,omitempty"`
+	NumVotes      *int     `json:"num_votes,omitempty"`
+	AverageRating *float64 `json:"average_rating,omitempty"`
+	Job           *string  `json:"job,omitempty"`
+	Characters    []string `json:"characters,omitempty"`
+}
+
+// FilmographyGroup is a person's credits in one category (actor, director...)
+type FilmographyGroup struct {
+	Category string         `json:"category"`
+	Credits  []PersonCredit `json:"credits"`
+}
+
+// PersonSearchResult is a match from /api/people?q=
+type PersonSearchResult struct {
+	PersonID          int      `json:"person_id"`
+	IMDbID            string   `json:"imdb_id"`
+	DisplayName       string   `json:"display_name"`
+	BirthYear         *int     `json:"birth_year,omitempty"`
+	DeathYear         *int     `json:"death_year,omitempty"`
+	PrimaryProfession []string `json:"primary_profession,omitempty"`
+	TitleVotes        int64    `json:"title_votes"`
+}
+
+const personCreditColumns = `t.id, t.type, t.display_name, t.start_year, t.end_year, m.id, s.id, t.image_url, t.image_blurhash, t.image_color, t.num_votes, t.average_rating`
+
+func (c *PersonCredit) dests() []any {
+	return []any{&c.TitleID, &c.Type, &c.DisplayName, &c.StartYear, &c.EndYear, &c.MovieID, &c.ShowID, &c.ImageURL, &c.ImageBlurhash, &c.ImageColor, &c.NumVotes, &c.AverageRating}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func getPersonByID(id int) (Person, error) {
+	var p Person
+	var professions, knownFor string
+	err := db.QueryRow(`
+		SELECT id, imdb_id, display_name, birth_year, death_year, COALESCE(primary_profession, ''), COALESCE(known_for, '')
+		FROM people WHERE id = $1
+	`, id).Scan(&p.PersonID, &p.IMDbID, &p.DisplayName, &p.BirthYear, &p.DeathYear, &professions, &knownFor)
+	if err != nil {
+		return p, err
+	}
+	p.PrimaryProfession = splitList(professions)
+	p.KnownFor = []PersonCredit{}
+	p.Filmography = []FilmographyGroup{}
+
+	// Known for: IMDb's picks, in IMDb's order, limited to titles we have
+	if tconsts := splitList(knownFor); len(tconsts) > 0 {
+		rows, err := db.Query(`
+			SELECT `+personCreditColumns+`, t.imdb_id
+			FROM titles t
+			LEFT JOIN movies m ON m.title_id = t.id
+			LEFT JOIN shows s ON s.title_id = t.id
+			WHERE t.imdb_id = ANY($1)`, pq.Array(tconsts))
+		if err == nil {
+			byImdb := make(map[string]PersonCredit)
+			for rows.Next() {
+				var c PersonCredit
+				var imdbID string
+				rows.Scan(append(c.dests(), &imdbID)...)
+				byImdb[imdbID] = c
+			}
+			rows.Close()
+			for _, tc := range tconsts {
+				if c, ok := byImdb[tc]; ok {
+					p.KnownFor = append(p.KnownFor, c)
+				}
+			}
+		}
+	}
+
+	// Filmography, newest first within each category
+	rows, err := db.Query(`
+		SELECT c.category, c.job, c.characters, `+personCreditColumns+`
+		FROM title_credits c
+		JOIN titles t ON t.id = c.title_id
+		LEFT JOIN movies m ON m.title_id = t.id
+		LEFT JOIN shows s ON s.title_id = t.id
+		WHERE c.person_id = $1
+		ORDER BY t.start_year DESC NULLS FIRST, t.num_votes DESC NULLS LAST`, id)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	groups := make(map[string]int)
+	for rows.Next() {
+		var c PersonCredit
+		var category string
+		if err := rows.Scan(append([]any{&category, &c.Job, pq.Array(&c.Characters)}, c.dests()...)...); err != nil {
+			return p, err
+		}
+		i, ok := groups[category]
+		if !ok {
+			i = len(p.Filmography)
+			groups[category] = i
+			p.Filmography = append(p.Filmography, FilmographyGroup{Category: category})
+		}
+		p.Filmography[i].Credits = append(p.Filmography[i].Credits, c)
+	}
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	// Largest group first: an actor who directed once is listed as an actor
+	sort.SliceStable(p.Filmography, func(i, j int) bool {
+		return len(p.Filmography[i].Credits) > len(p.Filmography[j].Credits)
+	})
+
+	for i := range p.KnownFor {
+		c := &p.KnownFor[i]
+		nilIfEmpty(&c.ImageURL)
+		c.ImageURL = proxyImage("poster", c.TitleID, c.ImageURL)
+	}
+	for _, g := range p.Filmography {
+		for i := range g.Credits {
+			c := &g.Credits[i]
+			nilIfEmpty(&c.ImageURL)
+			c.ImageURL = proxyImage("poster", c.TitleID, c.ImageURL)
+		}
+	}
+	return p, nil
+}
+
+// nilIfEmpty turns an empty image_url into nil, so it is omitted.
+func nilIfEmpty(p **string) {
+	if !hasImage(*p) {
+		*p = nil
+	}
+}
+
+// searchPeople matches people by name. As in title search, an exact name
+// beats a prefix beats a substring, and ln(total votes of their titles) is
+// added so well-known people rank first within a tier. The totals are
+// people.title_votes, kept by cmd/sync, and the total count comes from the
+// same scan.
+func searchPeople(q string, limit, offset int) ([]PersonSearchResult, int, error) {
+	q = strings.TrimSpace(q)
+	pat := likeEscape(q)
+
+	rows, err := db.Query(`
+		SELECT p.id, p.imdb_id, p.display_name, p.birth_year, p.death_year, COALESCE(p.primary_profession, ''),
+			COALESCE(p.title_votes, 0), COUNT(*) OVER ()
+		FROM people p
+		WHERE p.display_name ILIKE '%' || $2 || '%'
+		ORDER BY (CASE
+			WHEN lower(p.display_name) = lower($1) THEN 40
+			WHEN p.display_name ILIKE $2 || '%' THEN 30
+			ELSE 10 END) + LN(COALESCE(p.title_votes, 0) + 1) DESC, p.id
+		LIMIT $3 OFFSET $4`, q, pat, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []PersonSearchResult{}
+	total := 0
+	for rows.Next() {
+		var p PersonSearchResult
+		var professions string
+		if err := rows.Scan(&p.PersonID, &p.IMDbID, &p.DisplayName, &p.BirthYear, &p.DeathYear, &professions, &p.TitleVotes, &total); err != nil {
+			return nil, 0, err
+		}
+		p.PrimaryProfession = splitList(professions)
+		results = append(results, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 && offset > 0 {
+		// Past the last page: the window saw no rows to count
+		err = db.QueryRow(`SELECT COUNT(*) FROM people WHERE display_name ILIKE '%' || $1 || '%'`, pat).Scan(&total)
+	}
+	return results, total, err
+}
+
+func handleAPIPeople(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		jsonError(w, "q is required", 400)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	people, total, err := searchPeople(q, perPage, (page-1)*perPage)
+	if err != nil {
+		log.Printf("searchPeople error: %v", err)
+		jsonError(w, "Database error", 500)
+		return
+	}
+	jsonResponse(w, map[string]any{
+		"people":      people,
+		"total":       total,
+		"page":        page,
+		"per_page":    perPage,
+		"total_pages": (total + perPage - 1) / perPage,
+	})
+}
+
+func handleAPIPerson(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/people/"))
+	if err != nil {
+		jsonError(w, "Invalid ID", 400)
+		return
+	}
+	person, err := getPersonByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonError(w, "Not found", 404)
+		return
+	}
+	if err != nil {
+		log.Printf("Loading person %d: %v", id, err)
+		jsonError(w, "Database error", 500)
+		return
+	}
+	jsonResponse(w, person)
+}
+
+func handlePersonPage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/people/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	person, err := getPersonByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Loading person %d: %v", id, err)
+		http.Error(w, "Database error", 500)
+		return
+	}
+	tmpls["person"].ExecuteTemplate(w, "base", person)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"mediacanon.org/backend/internal/imagecache"
+)
+
+// Placeholders
+//
+// Poster grids flash blank tiles while posters load. Titles and episodes
+// carry image_blurhash (a BlurHash, decoded by the client into a blurred
+// preview) and image_color (the dominant colour, for clients that only paint
+// a background) to show meanwhile.
+//
+// Both are computed by the enrichment workers from TMDB's smallest (w92)
+// rendition of the poster or still (imageCache.Placeholder), right after the
+// title or episodes job has stored it; originals are never downloaded for
+// them.
+// placeholder_path records the file they came from: when TMDB sends a new
+// poster they are recomputed, and until then the old ones are served.
+// Failures go through enrichment_status like other TMDB fetches, as
+// entity_type poster (title ids) and still (episode ids).
+
+// placeholderDue reports whether the title's poster placeholder is missing
+// or stale and may be computed now.
+func placeholderDue(t *Title) bool {
+	return imageCache != nil && t.PosterPath != nil && *t.PosterPath != "" &&
+		(t.PlaceholderPath == nil || *t.PlaceholderPath != *t.PosterPath) &&
+		!enrichmentBlocked(entityPoster, []int{t.TitleID})[t.TitleID]
+}
+
+// fillPlaceholders computes the placeholders of titles' posters (entity
+// poster) or episodes' stills (entity still) that are missing or stale,
+// stopping when ctx is done.
+func fillPlaceholders(ctx context.Context, entity string, ids []int) {
+	if imageCache == nil || len(ids) == 0 {
+		return
+	}
+	table, column := "titles", "poster_path"
+	if entity == entityStill {
+		table, column = "show_episodes", "still_path"
+	}
+	rows, err := db.Query(`SELECT id, `+column+` FROM `+table+`
+		WHERE id = ANY($1) AND `+column+` <> '' AND `+column+` IS DISTINCT FROM placeholder_path`, pq.Array(ids))
+	if err != nil {
+		return
+	}
+	type image struct {
+		id   int
+		path string
+	}
+	var todo []image
+	var todoIDs []int
+	for rows.Next() {
+		var im image
+		rows.Scan(&im.id, &im.path)
+		todo = append(todo, im)
+		todoIDs = append(todoIDs, im.id)
+	}
+	rows.Close()
+
+	blocked := enrichmentBlocked(entity, todoIDs)
+	var found, notFound []int
+	for _, im := range todo {
+		if blocked[im.id] || ctx.Err() != nil {
+			continue
+		}
+		p, err := imageCache.Placeholder(ctx, strings.TrimPrefix(im.path, "/"))
+		switch {
+		case errors.Is(err, imagecache.ErrNotFound), errors.Is(err, imagecache.ErrUnsupported):
+			notFound = append(notFound, im.id)
+		case err != nil:
+			if ctx.Err() == nil {
+				recordEnrichment(entity, []int{im.id}, statusError, err.Error())
+			}
+		default:
+			_, err = db.Exec(`UPDATE `+table+` SET image_blurhash = $2, image_color = $3, placeholder_path = $4 WHERE id = $1`,
+				im.id, p.BlurHash, p.Color, im.path)
+			if err != nil {
+				log.Printf("Failed to store %s placeholder for %d: %v", entity, im.id, err)
+				continue
+			}
+			found = append(found, im.id)
+		}
+	}
+	recordEnrichment(entity, found, statusFound, "")
+	recordEnrichment(entity, notFound, statusNotFound, "")
+	if len(found) > 0 {
+		log.Printf("Computed %d %s placeholders", len(found), entity)
+	}
+}
+
+// queuePlaceholders queues the title job for discover titles showing a TMDB
+// poster with no placeholder. Nothing else enriches titles on discover pages,
+// and they are where placeholders matter most.
+func queuePlaceholders(titles []DiscoverTitle) {
+	if imageCache == nil {
+		return
+	}
+	var ids []int
+	for _, t := range titles {
+		if t.ImageURL != nil && strings.HasPrefix(*t.ImageURL, tmdbImageBase) && t.ImageBlurhash == nil {
+			ids = append(ids, t.TitleID)
+		}
+	}
+	blocked := enrichmentBlocked(entityPoster, ids)
+	ids = slices.DeleteFunc(ids, func(id int) bool { return blocked[id] })
+	queueEnrichment(jobTitle, priorityList, ids...)
+}
+
+// showEpisodeIDs lists the ids of a loaded show's episodes.
+func showEpisodeIDs(show *Show) []int {
+	var ids []int
+	for _, s := range show.Seasons {
+		for _, e := range s.Episodes {
+			ids = append(ids, e.EpisodeID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// Title filters and query syntax
+//
+// titleFilter is the one description of "which titles" shared by /titles,
+// /api/titles and the discover endpoints. It can be filled from URL params or
+// parsed from a single search box with parseTitleQuery:
+//
+//	drama year:2010..2015 lang:ko rating:>8 votes:>10k type:show
+//
+// Bare words (and "quoted phrases") become the free-text title search. A
+// word is only treated as a filter when its key is known, so titles like
+// "Mission: Impossible" still search as text.
+
+// numRange is an optional lower and upper bound on a numeric column.
+type numRange struct {
+	Min          *float64 `json:"min,omitempty"`
+	Max          *float64 `json:"max,omitempty"`
+	MinExclusive bool     `json:"min_exclusive,omitempty"`
+	MaxExclusive bool     `json:"max_exclusive,omitempty"`
+}
+
+// titleFilter holds title constraints. Langs and Countries match any listed
+// value. Each Genres group matches any of its names, and every group must match.
+type titleFilter struct {
+	Text      string     `json:"text,omitempty"`
+	Type      string     `json:"type,omitempty"`
+	Langs     []string   `json:"langs,omitempty"`
+	Countries []string   `json:"countries,omitempty"`
+	Genres    [][]string `json:"genres,omitempty"`
+	Year      *numRange  `json:"year,omitempty"`
+	Rating    *numRange  `json:"rating,omitempty"`
+	Votes     *numRange  `json:"votes,omitempty"`
+	Runtime   *numRange  `json:"runtime,omitempty"`
+}
+
+// sqlWhere accumulates AND conditions and their positional arguments.
+type sqlWhere struct {
+	clause string
+	args   []any
+}
+
+// newSQLWhere starts a WHERE clause; base may be "" or a leading condition.
+func newSQLWhere(base string) *sqlWhere {
+	if base == "" {
+		base = "1=1"
+	}
+	return &sqlWhere{clause: " WHERE " + base}
+}
+
+// arg appends v and returns its placeholder.
+func (w *sqlWhere) arg(v any) string {
+	w.args = append(w.args, v)
+	return "$" + strconv.Itoa(len(w.args))
+}
+
+func (w *sqlWhere) and(cond string) {
+	w.clause += " AND " + cond
+}
+
+// clone returns a copy that can be extended without affecting w.
+func (w *sqlWhere) clone() *sqlWhere {
+	return &sqlWhere{clause: w.clause, args: append([]any{}, w.args...)}
+}
+
+func (w *sqlWhere) String() string {
+	return w.clause
+}
+
+// apply adds f's conditions to w. When f has free text it returns the
+// relevance expression for titleSortOrder, otherwise "".
+func (f titleFilter) apply(w *sqlWhere) (relevance string) {
+	if f.Text != "" {
+		n := len(w.args) + 1
+		w.args = append(w.args, titleSearchArgs(f.Text)...)
+		w.clause += titleSearchWhere(n)
+		relevance = titleRelevanceExpr(n)
+	}
+	if f.Type != "" {
+		w.and("t.type = " + w.arg(f.Type))
+	}
+	if len(f.Langs) == 1 {
+		w.and("t.original_language = " + w.arg(f.Langs[0]))
+	} else if len(f.Langs) > 1 {
+		w.and("t.original_language = ANY(" + w.arg(pq.Array(f.Langs)) + ")")
+	}
+	if len(f.Countries) == 1 {
+		w.and("t.origin_country = " + w.arg(f.Countries[0]))
+	} else if len(f.Countries) > 1 {
+		w.and("t.origin_country = ANY(" + w.arg(pq.Array(f.Countries)) + ")")
+	}
+	for _, group := range f.Genres {
+		names := make([]string, len(group))
+		for i, g := range group {
+			names[i] = strings.ToLower(g)
+		}
+		w.and("EXISTS(SELECT 1 FROM title_genres tg JOIN genres g ON tg.genre_id = g.id WHERE tg.title_id = t.id AND lower(g.name) = ANY(" + w.arg(pq.Array(names)) + "))")
+	}
+	f.Year.apply(w, "t.start_year", true)
+	f.Rating.apply(w, "t.average_rating", false)
+	f.Votes.apply(w, "t.num_votes", true)
+	f.Runtime.apply(w, "t.runtime_minutes", true)
+	return relevance
+}
+
+func (r *numRange) apply(w *sqlWhere, col string, isInt bool) {
+	if r == nil {
+		return
+	}
+	val := func(v float64) any {
+		if isInt {
+			return int(v)
+		}
+		return v
+	}
+	if r.Min != nil {
+		op := " >= "
+		if r.MinExclusive {
+			op = " > "
+		}
+		w.and(col + op + w.arg(val(*r.Min)))
+	}
+	if r.Max != nil {
+		op := " <= "
+		if r.MaxExclusive {
+			op = " < "
+		}
+		w.and(col + op + w.arg(val(*r.Max)))
+	}
+}
+
+// hasFilters reports whether f constrains anything besides free text.
+func (f titleFilter) hasFilters() bool {
+	return f.Type != "" || len(f.Langs) > 0 || len(f.Countries) > 0 || len(f.Genres) > 0 ||
+		f.Year != nil || f.Rating != nil || f.Votes != nil || f.Runtime != nil
+}
+
+// queryError is a parse error in a title query. Pos is the byte offset of the
+// offending token.
+type queryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Msg, e.Pos)
+}
+
+// queryErrorResponse writes a 400 with the parse error and its position.
+func queryErrorResponse(w http.ResponseWriter, err *queryError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	json.NewEncoder(w).Encode(map[string]any{"error": err.Msg, "position": err.Pos})
+}
+
+type queryToken struct {
+	pos   int
+	raw   string // token as typed, quotes included
+	text  string
+	key   string // lower-cased filter name, "" for free text
+	value string
+}
+
+// tokenizeQuery splits s on whitespace, keeping "double quoted" runs together
+// (both as whole tokens and as filter values, e.g. genre:"film-noir").
+func tokenizeQuery(s string) ([]queryToken, *queryError) {
+	var tokens []queryToken
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' || s[i] == '\t' || s[i] == '\n' {
+			i++
+			continue
+		}
+		start := i
+		var b strings.Builder
+		quoted := false
+		for i < len(s) && (quoted || (s[i] != ' ' && s[i] != '\t' && s[i] != '\n')) {
+			if s[i] == '"' {
+				quoted = !quoted
+			} else {
+				b.WriteByte(s[i])
+			}
+			i++
+		}
+		if quoted {
+			return nil, &queryError{start, "unterminated quote"}
+		}
+		raw := s[start:i]
+		tok := queryToken{pos: start, raw: raw, text: b.String()}
+		if k, v, ok := strings.Cut(raw, ":"); ok && !strings.Contains(k, `"`) {
+			if _, known := queryFilters[strings.ToLower(k)]; known {
+				tok.key = strings.ToLower(k)
+				tok.value = strings.ReplaceAll(v, `"`, "")
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// queryFilters maps each filter key to the function that applies its value.
+var queryFilters map[string]func(f *titleFilter, value string) error
+
+func init() {
+	// list splits a comma-separated value, normalizing and validating each item
+	list := func(value string, normalize func(string) string, valid func(string) bool, what string) ([]string, error) {
+		var items []string
+		for _, v := range strings.Split(value, ",") {
+			v = normalize(strings.TrimSpace(v))
+			if !valid(v) {
+				return nil, fmt.Errorf("invalid %s %q", what, v)
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	isCode := func(min, max int) func(string) bool {
+		return func(s string) bool {
+			if len(s) < min || len(s) > max {
+				return false
+			}
+			for _, r := range s {
+				if !unicode.IsLetter(r) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	queryFilters = map[string]func(*titleFilter, string) error{
+		"type": func(f *titleFilter, v string) error {
+			switch strings.ToLower(v) {
+			case "movie", "movies", "film":
+				f.Type = "movie"
+			case "show", "shows", "tv", "series":
+				f.Type = "show"
+			default:
+				return fmt.Errorf("type must be movie or show, got %q", v)
+			}
+			return nil
+		},
+		"lang": func(f *titleFilter, v string) error {
+			langs, err := list(v, strings.ToLower, isCode(2, 3), "language code")
+			f.Langs = append(f.Langs, langs...)
+			return err
+		},
+		"country": func(f *titleFilter, v string) error {
+			countries, err := list(v, strings.ToUpper, isCode(2, 2), "country code")
+			f.Countries = append(f.Countries, countries...)
+			return err
+		},
+		"genre": func(f *titleFilter, v string) error {
+			group, err := list(v, strings.TrimSpace, func(s string) bool { return s != "" }, "genre")
+			if err == nil {
+				f.Genres = append(f.Genres, group)
+			}
+			return err
+		},
+		"year":    rangeFilter(func(f *titleFilter) **numRange { return &f.Year }, true, "year"),
+		"rating":  rangeFilter(func(f *titleFilter) **numRange { return &f.Rating }, false, "rating"),
+		"votes":   rangeFilter(func(f *titleFilter) **numRange { return &f.Votes }, true, "votes"),
+		"runtime": rangeFilter(func(f *titleFilter) **numRange { return &f.Runtime }, true, "runtime"),
+	}
+	queryFilters["language"] = queryFilters["lang"]
+	queryFilters["genres"] = queryFilters["genre"]
+}
+
+// rangeFilter parses "N", ">N", ">=N", "<N", "<=N", "A..B", "A.." and "..B".
+// Integer fields accept k/m suffixes, so votes:>10k works.
+func rangeFilter(field func(*titleFilter) **numRange, isInt bool, what string) func(*titleFilter, string) error {
+	parse := func(s string) (float64, error) {
+		mult := 1.0
+		if isInt && len(s) > 1 {
+			switch s[len(s)-1] {
+			case 'k', 'K':
+				mult, s = 1e3, s[:len(s)-1]
+			case 'm', 'M':
+				mult, s = 1e6, s[:len(s)-1]
+			}
+		}
+		// ParseFloat accepts NaN and Inf, which would reach SQL
+		n, err := strconv.ParseFloat(s, 64)
+		n *= mult
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, fmt.Errorf("invalid %s %q", what, s)
+		}
+		if isInt && n != float64(int(n)) {
+			return 0, fmt.Errorf("%s must be a whole number, got %q", what, s)
+		}
+		return n, nil
+	}
+	return func(f *titleFilter, v string) error {
+		var r numRange
+		switch {
+		case strings.Contains(v, ".."):
+			lo, hi, _ := strings.Cut(v, "..")
+			if lo == "" && hi == "" {
+				return fmt.Errorf("empty %s range", what)
+			}
+			if lo != "" {
+				n, err := parse(lo)
+				if err != nil {
+					return err
+				}
+				r.Min = &n
+			}
+			if hi != "" {
+				n, err := parse(hi)
+				if err != nil {
+					return err
+				}
+				r.Max = &n
+			}
+			if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+				return fmt.Errorf("%s range %s is backwards", what, v)
+			}
+		case strings.HasPrefix(v, ">"):
+			rest, inclusive := strings.CutPrefix(v, ">=")
+			if !inclusive {
+				rest = strings.TrimPrefix(v, ">")
+			}
+			r.MinExclusive = !inclusive
+			n, err := parse(rest)
+			if err != nil {
+				return err
+			}
+			r.Min = &n
+		case strings.HasPrefix(v, "<"):
+			rest, inclusive := strings.CutPrefix(v, "<=")
+			if !inclusive {
+				rest = strings.TrimPrefix(v, "<")
+			}
+			r.MaxExclusive = !inclusive
+			n, err := parse(rest)
+			if err != nil {
+				return err
+			}
+			r.Max = &n
+		default:
+			n, err := parse(v)
+			if err != nil {
+				return err
+			}
+			r.Min, r.Max = &n, &n
+		}
+		*field(f) = &r
+		return nil
+	}
+}
+
+// parseTitleQuery parses the search box syntax described at the top of this
+// file. Free-text words are joined with single spaces into f.Text.
+func parseTitleQuery(s string) (titleFilter, *queryError) {
+	var f titleFilter
+	tokens, qerr := tokenizeQuery(s)
+	if qerr != nil {
+		return f, qerr
+	}
+	var text []string
+	for _, tok := range tokens {
+		if tok.key == "" {
+			if tok.text != "" {
+				text = append(text, tok.text)
+			}
+			continue
+		}
+		if tok.value == "" {
+			return f, &queryError{tok.pos, fmt.Sprintf("%s: needs a value", tok.key)}
+		}
+		if err := queryFilters[tok.key](&f, tok.value); err != nil {
+			return f, &queryError{tok.pos, err.Error()}
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, nil
+}
+
+// discoverFilter builds a filter from the discover page's URL params. Values
+// that don't parse are ignored, as they always have been on discover.
+func discoverFilter(typeFilter, langFilter, genre, country, yearMin, ratingMin, minVotes string) titleFilter {
+	f := titleFilter{Type: typeFilter}
+	if langFilter != "" {
+		f.Langs = []string{langFilter}
+	}
+	if country != "" {
+		f.Countries = []string{country}
+	}
+	if genre != "" {
+		f.Genres = [][]string{{genre}}
+	}
+	atLeast := func(s string) *numRange {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return &numRange{Min: &n}
+		}
+		return nil
+	}
+	if _, err := strconv.Atoi(yearMin); err == nil {
+		f.Year = atLeast(yearMin)
+	}
+	f.Rating = atLeast(ratingMin)
+	if _, err := strconv.Atoi(minVotes); err == nil {
+		f.Votes = atLeast(minVotes)
+	}
+	return f
+}
+
+// mergeTitleFilters returns base with every field that is set in override
+// replaced by override's value.
+func mergeTitleFilters(base, override titleFilter) titleFilter {
+	if override.Text != "" {
+		base.Text = override.Text
+	}
+	if override.Type != "" {
+		base.Type = override.Type
+	}
+	if override.Langs != nil {
+		base.Langs = override.Langs
+	}
+	if override.Countries != nil {
+		base.Countries = override.Countries
+	}
+	if override.Genres != nil {
+		base.Genres = override.Genres
+	}
+	if override.Year != nil {
+		base.Year = override.Year
+	}
+	if override.Rating != nil {
+		base.Rating = override.Rating
+	}
+	if override.Votes != nil {
+		base.Votes = override.Votes
+	}
+	if override.Runtime != nil {
+		base.Runtime = override.Runtime
+	}
+	return base
+}
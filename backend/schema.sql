@@ -152,3 +152,187 @@ ALTER TABLE titles RENAME COLUMN year TO start_year;
 ALTER TABLE titles ADD COLUMN IF NOT EXISTS end_year INTEGER;
 DROP INDEX IF EXISTS idx_titles_year;
 CREATE INDEX IF NOT EXISTS idx_titles_start_year ON titles(start_year);
+
+-- Ranked title search: word matches via tsvector, substring matches via trigram.
+-- The tsvector expression must match titleSearchVector in search.go.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_titles_search_vector ON titles
+    USING GIN (to_tsvector('simple', display_name || ' ' || COALESCE(original_title, '')));
+CREATE INDEX IF NOT EXISTS idx_titles_display_name_trgm ON titles USING GIN (display_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_titles_original_title_trgm ON titles USING GIN (original_title gin_trgm_ops);
+
+-- Alternate titles (IMDb title.akas.tsv.gz), one row per (title, ordering)
+CREATE TABLE IF NOT EXISTS title_akas (
+    title_id INTEGER NOT NULL REFERENCES titles(id) ON DELETE CASCADE,
+    ordering INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    region VARCHAR(10),
+    language VARCHAR(10),
+    types TEXT,
+    is_original BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (title_id, ordering)
+);
+CREATE INDEX IF NOT EXISTS idx_title_akas_title_trgm ON title_akas USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_title_akas_region ON title_akas(region);
+
+-- External ID lookup by TMDB id (TMDB numbers movies and TV separately)
+CREATE INDEX IF NOT EXISTS idx_titles_type_tmdb_id ON titles(type, tmdb_id);
+
+-- Keyset pagination: one index per sort mode, matching titleKeyset in cursor.go
+CREATE INDEX IF NOT EXISTS idx_titles_keyset_votes ON titles ((COALESCE(num_votes, -1)), id);
+CREATE INDEX IF NOT EXISTS idx_titles_keyset_rating ON titles ((COALESCE(average_rating, -1)), (COALESCE(num_votes, -1)), id);
+CREATE INDEX IF NOT EXISTS idx_titles_keyset_popularity ON titles ((COALESCE(tmdb_popularity, -1)), id);
+CREATE INDEX IF NOT EXISTS idx_titles_keyset_newest ON titles ((COALESCE(start_year, -1)), (COALESCE(release_date, '0001-01-01'::date)), (COALESCE(num_votes, -1)), id);
+CREATE INDEX IF NOT EXISTS idx_titles_keyset_name ON titles (display_name, id);
+
+-- Episode search: matches episodeSearchVector in episode_search.go
+CREATE INDEX IF NOT EXISTS idx_show_episodes_search ON show_episodes USING GIN (to_tsvector('english', COALESCE(display_name, '') || ' ' || COALESCE(synopsis, '')));
+CREATE INDEX IF NOT EXISTS idx_show_episodes_display_name_trgm ON show_episodes USING GIN (display_name gin_trgm_ops);
+
+-- People and credits (IMDb name.basics, title.principals, title.crew), loaded by cmd/sync
+CREATE TABLE IF NOT EXISTS people (
+    id SERIAL PRIMARY KEY,
+    imdb_id VARCHAR(20) NOT NULL UNIQUE,
+    display_name VARCHAR(500) NOT NULL,
+    birth_year INTEGER,
+    death_year INTEGER,
+    primary_profession TEXT,
+    known_for TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- One row per billed principal, ordered as IMDb bills them; directors and
+-- writers listed only in title.crew follow the last principal
+CREATE TABLE IF NOT EXISTS title_credits (
+    title_id INTEGER NOT NULL REFERENCES titles(id) ON DELETE CASCADE,
+    ordering INTEGER NOT NULL,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    category VARCHAR(50) NOT NULL,
+    job TEXT,
+    characters TEXT[],
+    PRIMARY KEY (title_id, ordering)
+);
+CREATE INDEX IF NOT EXISTS idx_title_credits_person ON title_credits(person_id);
+CREATE INDEX IF NOT EXISTS idx_people_display_name_trgm ON people USING GIN (display_name gin_trgm_ops);
+
+-- Episode-level IMDb data: tconst from title.episode, ratings from title.ratings
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS imdb_id VARCHAR(20);
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS num_votes INTEGER;
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS average_rating REAL;
+CREATE INDEX IF NOT EXISTS idx_show_episodes_imdb_id ON show_episodes(imdb_id);
+
+-- Season metadata from TMDB /tv/{id}/season/{n}
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS display_name VARCHAR(500);
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS overview TEXT;
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS image_url TEXT;
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS air_date DATE;
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS episode_count INTEGER;
+
+-- Alternate episode orderings: where each of our (season, episode) sits in
+-- another numbering. 'absolute' is computed from our own numbering (specials
+-- excluded) as one flat season 1; 'aired' is TMDB's default numbering; 'dvd'
+-- and 'group:<id>' come from TMDB episode groups
+CREATE TABLE IF NOT EXISTS episode_orderings (
+    episode_id INTEGER NOT NULL REFERENCES show_episodes(id) ON DELETE CASCADE,
+    ordering VARCHAR(50) NOT NULL,
+    show_id INTEGER NOT NULL REFERENCES shows(id) ON DELETE CASCADE,
+    season INTEGER NOT NULL,
+    episode INTEGER NOT NULL,
+    PRIMARY KEY (episode_id, ordering)
+);
+CREATE INDEX IF NOT EXISTS idx_episode_orderings_show ON episode_orderings(show_id, ordering, season, episode);
+
+-- Episode calendar (/api/calendar, .ics feeds) filters on air date
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS air_date DATE;
+CREATE INDEX IF NOT EXISTS idx_show_episodes_air_date ON show_episodes(air_date);
+
+-- Show status from TMDB /tv/{id}; drives is_series_finished / is_season_finished.
+-- Episode positions are in TMDB's aired numbering
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS tmdb_status VARCHAR(50);
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS in_production BOOLEAN;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS next_air_date DATE;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS next_episode_season INTEGER;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS next_episode_number INTEGER;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS last_episode_season INTEGER;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS last_episode_number INTEGER;
+
+-- TMDB enrichment queued by request handlers and drained by in-process
+-- workers (enrichment.go). kind is 'title' (poster and metadata) or
+-- 'episodes'; locked_at is set while a worker runs the job
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    title_id INTEGER NOT NULL REFERENCES titles(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP,
+    PRIMARY KEY (title_id, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_next ON enrichment_jobs(priority DESC, created_at);
+
+-- Per-entity enrichment outcome and retry schedule (enrichment_status.go).
+-- entity_type is 'title' or 'episode'; status is 'found', 'not_found' or
+-- 'error'. next_retry_at is NULL once found
+CREATE TABLE IF NOT EXISTS enrichment_status (
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    source VARCHAR(30) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMP,
+    next_retry_at TIMESTAMP,
+    last_error TEXT,
+    PRIMARY KEY (entity_type, entity_id, source)
+);
+CREATE INDEX IF NOT EXISTS idx_enrichment_status_retry ON enrichment_status(entity_type, source, next_retry_at);
+
+-- image_url used to hold 'none' / 'TMDB_NOT_FOUND_DO_NOT_RETRY' for TMDB
+-- misses; those become not_found statuses, due for a retry in a week
+INSERT INTO enrichment_status (entity_type, entity_id, source, status, attempts, last_attempt_at, next_retry_at)
+SELECT 'title', id, 'tmdb', 'not_found', 1, NOW(), NOW() + INTERVAL '7 days'
+FROM titles WHERE image_url IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY')
+ON CONFLICT DO NOTHING;
+INSERT INTO enrichment_status (entity_type, entity_id, source, status, attempts, last_attempt_at, next_retry_at)
+SELECT 'episode', id, 'tmdb', 'not_found', 1, NOW(), NOW() + INTERVAL '7 days'
+FROM show_episodes WHERE image_url IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY')
+ON CONFLICT DO NOTHING;
+UPDATE titles SET image_url = NULL WHERE image_url IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY');
+UPDATE show_episodes SET image_url = NULL WHERE image_url IN ('none', 'TMDB_NOT_FOUND_DO_NOT_RETRY');
+
+-- TMDB file paths (e.g. /abc.jpg), from which image URLs of any size are
+-- built (images.go). image_url stays as the default-size URL
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS poster_path VARCHAR(100);
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS backdrop_path VARCHAR(100);
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS logo_path VARCHAR(100);
+ALTER TABLE show_seasons ADD COLUMN IF NOT EXISTS poster_path VARCHAR(100);
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS still_path VARCHAR(100);
+UPDATE titles SET poster_path = substring(image_url from '^https://image\.tmdb\.org/t/p/[^/]+(/.+)$')
+WHERE poster_path IS NULL AND image_url LIKE 'https://image.tmdb.org/t/p/%';
+UPDATE show_seasons SET poster_path = substring(image_url from '^https://image\.tmdb\.org/t/p/[^/]+(/.+)$')
+WHERE poster_path IS NULL AND image_url LIKE 'https://image.tmdb.org/t/p/%';
+UPDATE show_episodes SET still_path = substring(image_url from '^https://image\.tmdb\.org/t/p/[^/]+(/.+)$')
+WHERE still_path IS NULL AND image_url LIKE 'https://image.tmdb.org/t/p/%';
+
+-- Poster/still placeholders: a BlurHash and the dominant colour ('#rrggbb'),
+-- shown while the image loads. placeholder_path is the poster_path/still_path
+-- they were computed from; when it differs they are recomputed
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS image_blurhash VARCHAR(64);
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS image_color VARCHAR(7);
+ALTER TABLE titles ADD COLUMN IF NOT EXISTS placeholder_path VARCHAR(100);
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS image_blurhash VARCHAR(64);
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS image_color VARCHAR(7);
+ALTER TABLE show_episodes ADD COLUMN IF NOT EXISTS placeholder_path VARCHAR(100);
+
+-- TMDB episode groups (orderings.go): the group list last stored, as
+-- id:type:episode_count:group_count entries, and when. Groups are refetched
+-- only when the list changes or the check is 30 days old
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS episode_groups_key TEXT;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS episode_groups_checked_at TIMESTAMP;
+
+-- Enrichment jobs queued again while running (enrichment.go): the worker
+-- unlocks such a job when done, so it runs once more, instead of deleting it
+ALTER TABLE enrichment_jobs ADD COLUMN IF NOT EXISTS requeued BOOLEAN NOT NULL DEFAULT false;
+
+-- Total num_votes of each person's titles, maintained by cmd/sync after the
+-- ratings and credits imports. People search ranks by it (people.go)
+ALTER TABLE people ADD COLUMN IF NOT EXISTS title_votes BIGINT;
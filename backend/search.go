@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Title search
+//
+// Free-text title queries match on a 'simple' tsvector over display_name and
+// original_title (word matches in any order), a trigram-indexed ILIKE
+// substring match, or a substring match on any alternate title in title_akas.
+// These expressions have matching indexes in schema.sql, so the text used here
+// must stay in sync with idx_titles_search_vector and the *_trgm indexes.
+
+// titleSearchVector is the tsvector expression indexed by idx_titles_search_vector.
+const titleSearchVector = `to_tsvector('simple', t.display_name || ' ' || COALESCE(t.original_title, ''))`
+
+// likeEscape escapes LIKE wildcards so user input is matched literally.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// titleSearchArgs returns the two query arguments used by titleSearchWhere and
+// titleRelevanceExpr: the raw query and its LIKE-escaped form.
+func titleSearchArgs(q string) []any {
+	q = strings.TrimSpace(q)
+	return []any{q, likeEscape(q)}
+}
+
+// titleSearchWhere returns an " AND (...)" fragment matching q against title
+// names. argNum is the placeholder index of the first titleSearchArgs value.
+// The title_akas match is an uncorrelated IN, which Postgres runs once as a
+// hashed subplan rather than once per candidate title.
+func titleSearchWhere(argNum int) string {
+	raw := "$" + strconv.Itoa(argNum)
+	pat := "$" + strconv.Itoa(argNum+1)
+	return ` AND (` + titleSearchVector + ` @@ plainto_tsquery('simple', ` + raw + `)` +
+		` OR t.display_name ILIKE '%' || ` + pat + ` || '%'` +
+		` OR t.original_title ILIKE '%' || ` + pat + ` || '%'` +
+		` OR t.id IN (SELECT title_id FROM title_akas WHERE title ILIKE '%' || ` + pat + ` || '%'))`
+}
+
+// titleRelevanceExpr scores a matched title: an exact name match beats a
+// prefix match, which beats a whole-word match, which beats a bare substring
+// match. Alternate titles count for the exact and prefix tiers, so "Money
+// Heist" ranks La casa de papel as an exact hit. Each tier is worth 10 points
+// and ln(num_votes) (0-15 in practice) is added on top, so a very popular
+// prefix match can still outrank an obscure exact match with the same name.
+func titleRelevanceExpr(argNum int) string {
+	raw := "$" + strconv.Itoa(argNum)
+	pat := "$" + strconv.Itoa(argNum+1)
+	return `((CASE
+		WHEN lower(t.display_name) = lower(` + raw + `) OR lower(t.original_title) = lower(` + raw + `) THEN 40
+		WHEN t.id IN (SELECT title_id FROM title_akas WHERE title ILIKE ` + pat + `) THEN 40
+		WHEN t.display_name ILIKE ` + pat + ` || '%' OR t.original_title ILIKE ` + pat + ` || '%' THEN 30
+		WHEN t.id IN (SELECT title_id FROM title_akas WHERE title ILIKE ` + pat + ` || '%') THEN 30
+		WHEN ` + titleSearchVector + ` @@ plainto_tsquery('simple', ` + raw + `) THEN 20
+		ELSE 10 END) + LN(COALESCE(t.num_votes, 0) + 1))`
+}
+
+// titleSortOrder maps a sort mode to an ORDER BY clause for title listings.
+// relevance is the expression from titleRelevanceExpr, or "" when there is no
+// free-text query (in which case "relevance" falls back to vote count). The
+// keys come from titleKeyset so offset and cursor pages agree on order.
+func titleSortOrder(sortBy, relevance string) string {
+	return titleKeyset(sortBy, relevance).orderBy()
+}
+
+// validTitleSort reports whether sortBy is accepted by titleSortOrder.
+func validTitleSort(sortBy string) bool {
+	switch sortBy {
+	case "relevance", "most_rated", "top_rated", "newest", "a-z":
+		return true
+	}
+	return false
+}
+
+// TitleSuggestion is a "did you mean" candidate returned when a search has no hits
+type TitleSuggestion struct {
+	TitleID     int     `json:"title_id"`
+	Type        string  `json:"type"`
+	DisplayName string  `json:"display_name"`
+	StartYear   *int    `json:"start_year,omitempty"`
+	MovieID     *int    `json:"movie_id,omitempty"`
+	ShowID      *int    `json:"show_id,omitempty"`
+	NumVotes    *int    `json:"num_votes,omitempty"`
+	Similarity  float64 `json:"similarity"`
+}
+
+// fetchTitleSuggestions finds titles whose name is close to q by trigram
+// similarity (pg_trgm's % operator, served by the *_trgm indexes), to catch
+// typos like "Breking Bad". Candidates are ranked by similarity scaled up by
+// ln(num_votes), so a popular near-miss beats an obscure one.
+func fetchTitleSuggestions(q, typeFilter string, limit int) []TitleSuggestion {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil
+	}
+	where := ` WHERE (t.display_name % $1 OR t.original_title % $1)`
+	args := []any{q}
+	if typeFilter != "" {
+		where += ` AND t.type = $2`
+		args = append(args, typeFilter)
+	}
+	query := `
+		SELECT t.id, t.type, t.display_name, t.start_year, m.id, s.id, t.num_votes,
+		       GREATEST(similarity(t.display_name, $1), similarity(COALESCE(t.original_title, ''), $1)) AS sim
+		FROM titles t
+		LEFT JOIN movies m ON m.title_id = t.id
+		LEFT JOIN shows s ON s.title_id = t.id` + where + `
+		ORDER BY sim * (1 + LN(COALESCE(t.num_votes, 0) + 1) / 10) DESC
+		LIMIT ` + strconv.Itoa(limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("fetchTitleSuggestions error: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var suggestions []TitleSuggestion
+	for rows.Next() {
+		var sg TitleSuggestion
+		rows.Scan(&sg.TitleID, &sg.Type, &sg.DisplayName, &sg.StartYear, &sg.MovieID, &sg.ShowID, &sg.NumVotes, &sg.Similarity)
+		suggestions = append(suggestions, sg)
+	}
+	return suggestions
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"mediacanon.org/backend/internal/showstatus"
+	"mediacanon.org/backend/internal/tmdb"
+)
+
+// Show status
+//
+// TMDB's /tv/{id} carries the show's status ("Returning Series", "Ended",
+// "Canceled", "In Production", "Planned", "Pilot"), in_production, and its
+// last and next episodes. These are stored on shows (internal/showstatus) by
+// the lazy fetch and by cmd/sync's TMDB backfill, and drive
+// is_series_finished and is_season_finished.
+
+// showStatusColumns selects the stored status from shows s; scan into
+// Show.statusDests().
+const showStatusColumns = `s.tmdb_status, s.in_production, TO_CHAR(s.next_air_date, 'YYYY-MM-DD'),
+	s.next_episode_season, s.next_episode_number, s.last_episode_season, s.last_episode_number`
+
+func (s *Show) statusDests() []any {
+	return []any{&s.Status, &s.InProduction, &s.NextAirDate,
+		&s.nextEpisode.Season, &s.nextEpisode.Episode, &s.lastEpisode.Season, &s.lastEpisode.Episode}
+}
+
+// showEpisodeRef is a nullable TMDB (season, episode), in TMDB's aired numbering
+type showEpisodeRef struct {
+	Season  *int
+	Episode *int
+}
+
+func (r showEpisodeRef) pos() (orderingPos, bool) {
+	if r.Season == nil || r.Episode == nil {
+		return orderingPos{}, false
+	}
+	return orderingPos{*r.Season, *r.Episode}, true
+}
+
+// storeTMDBShowStatus saves st on the show row and copies it onto show.
+func storeTMDBShowStatus(show *Show, st *tmdb.TV) {
+	var next, last tmdb.EpisodeRef
+	if st.NextEpisodeToAir != nil {
+		next = *st.NextEpisodeToAir
+	}
+	if st.LastEpisodeToAir != nil {
+		last = *st.LastEpisodeToAir
+	}
+	err := showstatus.Store(db, show.Title.TitleID, st)
+	if err != nil {
+		log.Printf("Failed to store TMDB status for %s: %v", show.Title.DisplayName, err)
+		return
+	}
+
+	nonZero := func(n int) *int {
+		if n == 0 {
+			return nil
+		}
+		return &n
+	}
+	show.Status, show.NextAirDate = nil, nil
+	if st.Status != "" {
+		show.Status = &st.Status
+	}
+	show.InProduction = &st.InProduction
+	if next.AirDate != "" {
+		show.NextAirDate = &next.AirDate
+	}
+	show.nextEpisode = showEpisodeRef{nonZero(next.SeasonNumber), nonZero(next.EpisodeNumber)}
+	show.lastEpisode = showEpisodeRef{nonZero(last.SeasonNumber), nonZero(last.EpisodeNumber)}
+}
+
+// deriveFinishedFlags sets is_series_finished and is_season_finished.
+//
+// With a TMDB status, the series is finished once it is Ended or Canceled.
+// Otherwise a season is finished when none of its episodes airs after today
+// and TMDB's next episode is in a later season. With no next episode known
+// (between seasons, or a break with no date yet), a season is finished if it
+// is before TMDB's last aired episode's season, or that episode is the
+// season's last by TMDB's episode_count. TMDB positions are translated to
+// our seasons and episodes through the aired ordering; where that changes
+// the numbering, the season's last episode is our own last one.
+//
+// Without a status (never fetched) the old rule applies: finished if
+// end_year is set, and every season but the highest is finished.
+func deriveFinishedFlags(s *Show) {
+	if s.Status == nil {
+		finished := s.Title.EndYear != nil
+		s.IsSeriesFinished = &finished
+		maxSeason := 0
+		for _, sn := range s.Seasons {
+			maxSeason = max(maxSeason, sn.SeasonNumber)
+		}
+		for i := range s.Seasons {
+			sf := finished || s.Seasons[i].SeasonNumber < maxSeason
+			s.Seasons[i].IsSeasonFinished = &sf
+		}
+		return
+	}
+
+	finished := *s.Status == "Ended" || *s.Status == "Canceled"
+	s.IsSeriesFinished = &finished
+
+	// Our position for a TMDB aired position: via the stored aired
+	// ordering, else the same numbers
+	var byAired map[orderingPos]orderingPos
+	ourPos := func(p orderingPos) orderingPos {
+		if byAired == nil {
+			byAired = map[orderingPos]orderingPos{}
+			aired := loadEpisodeOrdering(s.ShowID, "aired")
+			for _, sn := range s.Seasons {
+				for _, ep := range sn.Episodes {
+					if ap, ok := aired[ep.EpisodeID]; ok {
+						byAired[ap] = orderingPos{sn.SeasonNumber, ep.EpisodeNumber}
+					}
+				}
+			}
+		}
+		if our, ok := byAired[p]; ok {
+			return our
+		}
+		return p
+	}
+
+	today := time.Now().Format("2006-01-02")
+	next, hasNext := s.nextEpisode.pos()
+	last, hasLast := s.lastEpisode.pos()
+	for i := range s.Seasons {
+		sn := &s.Seasons[i]
+		sf := finished
+		if !finished {
+			upcoming := false
+			for _, ep := range sn.Episodes {
+				if ep.AirDate != nil && *ep.AirDate > today {
+					upcoming = true
+				}
+			}
+			switch {
+			case upcoming:
+				sf = false
+			case hasNext:
+				sf = sn.SeasonNumber < ourPos(next).Season
+			case hasLast:
+				ourLast := ourPos(last)
+				sf = sn.SeasonNumber < ourLast.Season
+				if sn.SeasonNumber == ourLast.Season {
+					// episode_count counts TMDB's season of the same number,
+					// which is another season when TMDB numbers the show
+					// differently (e.g. one flat season); then our own last
+					// episode decides
+					if ourLast == last && sn.EpisodeCount != nil {
+						sf = last.Episode >= *sn.EpisodeCount
+					} else if ourLast != last && len(sn.Episodes) > 0 {
+						sf = ourLast.Episode >= sn.Episodes[len(sn.Episodes)-1].EpisodeNumber
+					}
+				}
+			}
+		}
+		sn.IsSeasonFinished = &sf
+	}
+}
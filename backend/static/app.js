@@ -19,3 +19,82 @@ document.querySelectorAll('.season-btn').forEach(btn => {
         });
     });
 });
+
+// TMDB enrichment queued for this title: wait for it, then reload once to
+// show the poster and episode data
+const pending = document.querySelector('[data-pending-title]');
+if (pending) {
+    const id = pending.dataset.pendingTitle;
+    const key = 'enriched-' + id;
+    if (!sessionStorage.getItem(key)) {
+        fetch('/api/titles/' + id + '/enrichment?wait=30')
+            .then(r => r.json())
+            .then(d => {
+                if (!d.pending_enrichment) {
+                    sessionStorage.setItem(key, '1');
+                    location.reload();
+                }
+            })
+            .catch(() => {});
+    }
+}
+
+// Poster placeholders: paint a card's BlurHash (data-blurhash) behind its
+// image, so the grid shows a blurred poster instead of a blank tile while
+// images load
+const BLURHASH_CHARS = '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~';
+
+function decodeBlurhash(hash, width, height) {
+    const d83 = s => [...s].reduce((v, c) => v * 83 + BLURHASH_CHARS.indexOf(c), 0);
+    const toLinear = v => { v /= 255; return v <= 0.04045 ? v / 12.92 : Math.pow((v + 0.055) / 1.055, 2.4); };
+    const toSRGB = v => {
+        v = Math.max(0, Math.min(1, v));
+        return Math.round(v <= 0.0031308 ? v * 12.92 * 255 : (1.055 * Math.pow(v, 1 / 2.4) - 0.055) * 255);
+    };
+    const size = d83(hash[0]);
+    const nx = size % 9 + 1, ny = Math.floor(size / 9) + 1;
+    if (hash.length !== 4 + 2 * nx * ny) return null;
+    const maxValue = (d83(hash[1]) + 1) / 166;
+    const dc = d83(hash.slice(2, 6));
+    const colors = [[toLinear(dc >> 16), toLinear((dc >> 8) & 255), toLinear(dc & 255)]];
+    for (let i = 1; i < nx * ny; i++) {
+        const v = d83(hash.slice(4 + i * 2, 6 + i * 2));
+        colors.push([Math.floor(v / 361), Math.floor(v / 19) % 19, v % 19]
+            .map(q => Math.sign(q - 9) * Math.pow((q - 9) / 9, 2) * maxValue));
+    }
+    const pixels = new Uint8ClampedArray(width * height * 4);
+    for (let y = 0; y < height; y++) {
+        for (let x = 0; x < width; x++) {
+            let r = 0, g = 0, b = 0;
+            for (let j = 0; j < ny; j++) {
+                for (let i = 0; i < nx; i++) {
+                    const basis = Math.cos(Math.PI * x * i / width) * Math.cos(Math.PI * y * j / height);
+                    const c = colors[i + j * nx];
+                    r += c[0] * basis;
+                    g += c[1] * basis;
+                    b += c[2] * basis;
+                }
+            }
+            const p = 4 * (x + y * width);
+            pixels[p] = toSRGB(r);
+            pixels[p + 1] = toSRGB(g);
+            pixels[p + 2] = toSRGB(b);
+            pixels[p + 3] = 255;
+        }
+    }
+    return pixels;
+}
+
+function blurhashPlaceholder(el) {
+    const w = 16, h = 24; // posters are 2:3
+    const pixels = decodeBlurhash(el.dataset.blurhash, w, h);
+    if (!pixels) return;
+    const canvas = document.createElement('canvas');
+    canvas.width = w;
+    canvas.height = h;
+    canvas.getContext('2d').putImageData(new ImageData(pixels, w, h), 0, 0);
+    el.style.backgroundImage = 'url(' + canvas.toDataURL() + ')';
+    el.style.backgroundSize = 'cover';
+}
+
+document.querySelectorAll('[data-blurhash]').forEach(blurhashPlaceholder);
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"cmp"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Typeahead suggestions
+//
+// /api/suggest is called on every keystroke, so it never touches the database.
+// It answers prefix queries from an in-memory index over the most-voted titles
+// (display names plus distinct akas). Every word position in a name is a key,
+// so "heist" and "money he" both reach "Money Heist". Keys are substrings of
+// the normalized name and share its memory.
+
+const defaultSuggestIndexSize = 100000
+
+// suggestShortPrefix is the longest query, in runes, answered from
+// suggestIndex.short. Prefixes this short match too many keys to walk per
+// keystroke ("the" and "man" match tens of thousands); the lists cost an
+// int32 per key and prefix length.
+const suggestShortPrefix = 3
+
+// SuggestResult is the compact payload returned by /api/suggest
+type SuggestResult struct {
+	TitleID       int      `json:"title_id"`
+	Type          string   `json:"type"`
+	DisplayName   string   `json:"display_name"`
+	StartYear     *int     `json:"start_year,omitempty"`
+	ImageURL      *string  `json:"image_url,omitempty"`
+	ImageBlurhash *string  `json:"image_blurhash,omitempty"`
+	ImageColor    *string  `json:"image_color,omitempty"`
+	MovieID       *int     `json:"movie_id,omitempty"`
+	ShowID        *int     `json:"show_id,omitempty"`
+	MatchedAka    string   `json:"matched_aka,omitempty"`
+	Akas          []string `json:"akas,omitempty"`
+}
+
+type suggestKey struct {
+	key     string // normalized name from a word boundary onwards
+	entry   int32  // index into suggestIndex.entries (lower = more votes)
+	name    int16  // 0 = display name, n = Akas[n-1]
+	atStart bool   // key starts at the beginning of the name
+}
+
+type suggestIndex struct {
+	entries []SuggestResult
+	keys    []suggestKey // sorted by key
+	// short lists, for each prefix of up to suggestShortPrefix runes, the
+	// keys starting with it in rank order (see suggestKeyRank), so a search
+	// stops after limit entries
+	short   map[string][]int32
+	builtAt time.Time
+	stamp   string
+}
+
+var (
+	suggestIdx   *suggestIndex
+	suggestIdxMu sync.RWMutex
+)
+
+// normalizeSuggest lower-cases s and collapses punctuation and whitespace to
+// single spaces, so "Spider-Man: No Way Home" indexes as "spider man no way home".
+func normalizeSuggest(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := true
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+		} else if r == '\'' || r == '’' {
+			continue // "Schindler's" -> "schindlers"
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// suggestIndexStamp changes whenever cmd/sync finishes a stage, which is when
+// names, votes or akas can change.
+func suggestIndexStamp() string {
+	var stamp string
+	db.QueryRow(`SELECT COALESCE(MAX(updated_at)::text, '') FROM sync_state`).Scan(&stamp)
+	return stamp
+}
+
+// buildSuggestIndex loads the top titles by num_votes and their akas and swaps
+// in a fresh index. SUGGEST_INDEX_SIZE overrides how many titles are indexed.
+func buildSuggestIndex() {
+	log.Println("Building suggest index...")
+	start := time.Now()
+
+	size := defaultSuggestIndexSize
+	if n, err := strconv.Atoi(os.Getenv("SUGGEST_INDEX_SIZE")); err == nil && n > 0 {
+		size = n
+	}
+	stamp := suggestIndexStamp()
+
+	rows, err := db.Query(`
+		SELECT t.id, t.type, t.display_name, t.start_year,
+		       t.image_url, t.image_blurhash, t.image_color,
+		       m.id, s.id
+		FROM titles t
+		LEFT JOIN movies m ON m.title_id = t.id
+		LEFT JOIN shows s ON s.title_id = t.id
+		WHERE t.num_votes IS NOT NULL
+		ORDER BY t.num_votes DESC, t.id
+		LIMIT $1`, size)
+	if err != nil {
+		log.Printf("buildSuggestIndex query error: %v", err)
+		return
+	}
+	idx := &suggestIndex{builtAt: time.Now(), stamp: stamp}
+	pos := make(map[int]int, size)
+	for rows.Next() {
+		var e SuggestResult
+		rows.Scan(&e.TitleID, &e.Type, &e.DisplayName, &e.StartYear, &e.ImageURL, &e.ImageBlurhash, &e.ImageColor, &e.MovieID, &e.ShowID)
+		pos[e.TitleID] = len(idx.entries)
+		idx.entries = append(idx.entries, e)
+	}
+	rows.Close()
+
+	// Distinct akas per title, skipping ones that normalize to the display name
+	akaRows, err := db.Query(`
+		SELECT a.title_id, a.title FROM title_akas a
+		WHERE a.title_id IN (SELECT id FROM titles WHERE num_votes IS NOT NULL ORDER BY num_votes DESC, id LIMIT $1)
+		ORDER BY a.title_id, a.ordering`, size)
+	if err == nil {
+		seen := make(map[string]bool)
+		lastID := 0
+		for akaRows.Next() {
+			var titleID int
+			var title string
+			akaRows.Scan(&titleID, &title)
+			i, ok := pos[titleID]
+			if !ok {
+				continue
+			}
+			if titleID != lastID {
+				clear(seen)
+				seen[normalizeSuggest(idx.entries[i].DisplayName)] = true
+				lastID = titleID
+			}
+			if n := normalizeSuggest(title); n != "" && !seen[n] && len(idx.entries[i].Akas) < 32 {
+				seen[n] = true
+				idx.entries[i].Akas = append(idx.entries[i].Akas, title)
+			}
+		}
+		akaRows.Close()
+	}
+
+	for i, e := range idx.entries {
+		idx.addKeys(normalizeSuggest(e.DisplayName), i, 0)
+		for j, aka := range e.Akas {
+			idx.addKeys(normalizeSuggest(aka), i, j+1)
+		}
+	}
+	sort.Slice(idx.keys, func(a, b int) bool { return idx.keys[a].key < idx.keys[b].key })
+	idx.buildShort()
+
+	suggestIdxMu.Lock()
+	suggestIdx = idx
+	suggestIdxMu.Unlock()
+
+	log.Printf("Suggest index built in %v: %d titles, %d keys", time.Since(start), len(idx.entries), len(idx.keys))
+}
+
+func (idx *suggestIndex) addKeys(norm string, entry, name int) {
+	if norm == "" {
+		return
+	}
+	idx.keys = append(idx.keys, suggestKey{norm, int32(entry), int16(name), true})
+	for i := 0; i < len(norm); i++ {
+		if norm[i] == ' ' && i+1 < len(norm) {
+			idx.keys = append(idx.keys, suggestKey{norm[i+1:], int32(entry), int16(name), false})
+		}
+	}
+}
+
+func (idx *suggestIndex) buildShort() {
+	idx.short = make(map[string][]int32)
+	for i, k := range idx.keys {
+		end := 0
+		for n := 0; n < suggestShortPrefix && end < len(k.key) && k.key[end] != ' '; n++ {
+			_, size := utf8.DecodeRuneInString(k.key[end:])
+			end += size
+			idx.short[k.key[:end]] = append(idx.short[k.key[:end]], int32(i))
+		}
+	}
+	for _, list := range idx.short {
+		slices.SortFunc(list, func(a, b int32) int { return suggestKeyRank(idx.keys[a], idx.keys[b]) })
+	}
+}
+
+// suggestKeyRank orders keys by how good a hit they make: start-of-name
+// matches first, then more votes, then the display name over akas.
+func suggestKeyRank(a, b suggestKey) int {
+	if a.atStart != b.atStart {
+		if a.atStart {
+			return -1
+		}
+		return 1
+	}
+	if a.entry != b.entry {
+		return cmp.Compare(a.entry, b.entry)
+	}
+	return cmp.Compare(a.name, b.name)
+}
+
+// refreshSuggestIndex rebuilds the index when cmd/sync has run since the last
+// build, or at least hourly so lazily fetched posters show up.
+func refreshSuggestIndex() {
+	suggestIdxMu.RLock()
+	idx := suggestIdx
+	suggestIdxMu.RUnlock()
+	if idx == nil || time.Since(idx.builtAt) > time.Hour || suggestIndexStamp() != idx.stamp {
+		buildSuggestIndex()
+	}
+}
+
+// search returns up to limit entries with a name that has a word starting with
+// q. Matches at the start of a name rank above mid-name matches; within a
+// tier, entries keep their num_votes order.
+func (idx *suggestIndex) search(q, typeFilter string, limit int) []SuggestResult {
+	// Short lists stop at the first word, so "a b" is walked
+	if utf8.RuneCountInString(q) <= suggestShortPrefix && !strings.Contains(q, " ") {
+		return idx.searchShort(q, typeFilter, limit)
+	}
+	lo := sort.Search(len(idx.keys), func(i int) bool { return idx.keys[i].key >= q })
+
+	best := make(map[int32]suggestKey)
+	for i := lo; i < len(idx.keys) && strings.HasPrefix(idx.keys[i].key, q); i++ {
+		k := idx.keys[i]
+		if typeFilter != "" && idx.entries[k.entry].Type != typeFilter {
+			continue
+		}
+		if h, ok := best[k.entry]; !ok || suggestKeyRank(k, h) < 0 {
+			best[k.entry] = k
+		}
+	}
+
+	hits := make([]suggestKey, 0, len(best))
+	for _, h := range best {
+		hits = append(hits, h)
+	}
+	slices.SortFunc(hits, suggestKeyRank)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return idx.results(hits)
+}
+
+// searchShort is search for prefixes of up to suggestShortPrefix runes: their
+// keys are already in rank order, so the first limit entries are the answer.
+func (idx *suggestIndex) searchShort(q, typeFilter string, limit int) []SuggestResult {
+	var hits []suggestKey
+	seen := make(map[int32]bool)
+	for _, i := range idx.short[q] {
+		if len(hits) == limit {
+			break
+		}
+		k := idx.keys[i]
+		if seen[k.entry] || (typeFilter != "" && idx.entries[k.entry].Type != typeFilter) {
+			continue
+		}
+		seen[k.entry] = true
+		hits = append(hits, k)
+	}
+	return idx.results(hits)
+}
+
+// results turns hits, one per entry, into the payload.
+func (idx *suggestIndex) results(hits []suggestKey) []SuggestResult {
+	results := make([]SuggestResult, len(hits))
+	for i, h := range hits {
+		r := idx.entries[h.entry]
+		if h.name > 0 {
+			r.MatchedAka = r.Akas[h.name-1]
+		}
+		if len(r.Akas) > 5 {
+			r.Akas = r.Akas[:5]
+		}
+		r.ImageURL = proxyImage("poster", r.TitleID, r.ImageURL)
+		results[i] = r
+	}
+	return results
+}
+
+func handleAPISuggest(w http.ResponseWriter, r *http.Request) {
+	if readOnly(w, r) {
+		return
+	}
+	q := normalizeSuggest(r.URL.Query().Get("q"))
+	typeFilter := r.URL.Query().Get("type")
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 50 {
+		limit = l
+	}
+
+	suggestIdxMu.RLock()
+	idx := suggestIdx
+	suggestIdxMu.RUnlock()
+
+	results := []SuggestResult{}
+	if q != "" && idx != nil {
+		results = idx.search(q, typeFilter, limit)
+	}
+	jsonResponse(w, map[string]any{"q": r.URL.Query().Get("q"), "results": results})
+}